@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, slog.LevelInfo, "logfmt")
+	if err != nil {
+		t.Fatalf("newLogger: %v", err)
+	}
+	logger.Debug("hidden")
+	logger.Warn("Error collecting stats", "target", "192.0.2.1:2001", "reason", "timeout")
+	if got := buf.String(); strings.Contains(got, "hidden") || !strings.Contains(got, `level=WARN msg="Error collecting stats" target=192.0.2.1:2001 reason=timeout`) {
+		t.Errorf("logfmt output = %q", got)
+	}
+
+	buf.Reset()
+	if logger, err = newLogger(&buf, slog.LevelDebug, "json"); err != nil {
+		t.Fatalf("newLogger: %v", err)
+	}
+	logger.Debug("Scraped accel-ppp", "target", "192.0.2.1:2001")
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil || line["target"] != "192.0.2.1:2001" {
+		t.Errorf("json output = %q (%v)", buf.String(), err)
+	}
+
+	if _, err := newLogger(&buf, slog.LevelInfo, "xml"); err == nil {
+		t.Error("newLogger accepted format xml")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{"debug": slog.LevelDebug, "info": slog.LevelInfo, "WARN": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := parseLevel(in); err != nil || got != want {
+			t.Errorf("parseLevel(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseLevel("loud"); err == nil {
+		t.Error("parseLevel accepted loud")
+	}
+}
@@ -3,15 +3,18 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"slices"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/taihen/accel-exporter/pkg/collector"
 	"github.com/taihen/accel-exporter/pkg/config"
+	"github.com/taihen/accel-exporter/pkg/parser"
 )
 
 // Version information set by build flags
@@ -29,12 +32,30 @@ func versionInfo() string {
 func main() {
 	cfg := config.NewConfig()
 
-	log.Printf("Starting %s", versionInfo())
-	log.Printf("Listening on %s, metrics path: %s", cfg.ListenAddress, cfg.MetricsPath)
+	// The level is a LevelVar so that reloads can change it.
+	level := new(slog.LevelVar)
+	l, err := parseLevel(cfg.LogLevel)
+	if err == nil {
+		level.Set(l)
+		var logger *slog.Logger
+		if logger, err = newLogger(os.Stderr, level, cfg.LogFormat); err == nil {
+			slog.SetDefault(logger)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid logging configuration: %v\n", err)
+		os.Exit(2)
+	}
+
+	slog.Info("Starting accel-exporter", "version", version, "commit", commit, "date", date)
+	slog.Info("Listening", "address", cfg.ListenAddress, "metrics_path", cfg.MetricsPath)
 
-	// Create and register collector
-	accelCollector := collector.NewAccelCollector(cfg.AccelCmdPath, cfg.ScrapeTimeout)
-	prometheus.MustRegister(accelCollector)
+	e, err := newExporter(prometheus.DefaultRegisterer, cfg, level)
+	if err != nil {
+		slog.Error("Invalid configuration", "err", err)
+		os.Exit(1)
+	}
+	e.reloadOnSIGHUP()
 
 	// Add version information
 	buildInfo := prometheus.NewGaugeVec(
@@ -49,37 +70,181 @@ func main() {
 
 	// Set up HTTP server with an explicit mux and timeouts. ReadHeaderTimeout
 	// guards against Slowloris-style header dribbling; WriteTimeout is kept
-	// comfortably above the scrape timeout so a legitimately slow scrape is
-	// never truncated.
+	// comfortably above the scrape timeout (see writeTimeout).
 	mux := http.NewServeMux()
-	mux.Handle(cfg.MetricsPath, promhttp.Handler())
+	mux.Handle(cfg.MetricsPath, e.metricsHandler(prometheus.DefaultRegisterer, prometheus.DefaultGatherer))
+	mux.Handle("/status", e.statusHandler())
+	mux.Handle("/probe", e.probeHandler())
+	mux.Handle("/-/reload", e.reloadHandler())
 	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		var probe string
+		if e.current.Load().probe != nil {
+			probe = `<p><a href="/probe?target=127.0.0.1:2001">Probe</a> (scrape a remote accel-ppp CLI)</p>`
+		}
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		_, _ = fmt.Fprintf(w, `<html>
 			<head><title>Accel-PPP Exporter</title></head>
 			<body>
 				<h1>Accel-PPP Exporter</h1>
 				<p><a href="%s">Metrics</a></p>
+				<p><a href="/status">Status</a> (last scrape error)</p>
+				%s
 				<p><small>%s</small></p>
 			</body>
-		</html>`, cfg.MetricsPath, versionInfo())
+		</html>`, cfg.MetricsPath, probe, versionInfo())
 	})
 
+	srv := &http.Server{
+		Addr:              cfg.ListenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      e.writeTimeout,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	err = srv.ListenAndServe()
+	slog.Error("HTTP server stopped", "err", err)
+	os.Exit(1)
+}
+
+// writeTimeout returns the HTTP write timeout cfg needs: comfortably above
+// the longest scrape, i.e. the scrape timeout or any module's, so a
+// legitimately slow scrape is never truncated.
+func writeTimeout(cfg *config.Config) time.Duration {
 	// Mirror the collector's clamp so a non-positive -accel-cmd.timeout cannot
 	// produce a too-short WriteTimeout that would truncate a legitimate scrape.
 	scrapeTimeout := cfg.ScrapeTimeout
 	if scrapeTimeout <= 0 {
 		scrapeTimeout = collector.DefaultScrapeTimeout
 	}
+	for _, m := range cfg.Modules {
+		scrapeTimeout = max(scrapeTimeout, m.Timeout)
+	}
+	return scrapeTimeout + 10*time.Second
+}
 
-	srv := &http.Server{
-		Addr:              cfg.ListenAddress,
-		Handler:           mux,
-		ReadHeaderTimeout: 5 * time.Second,
-		ReadTimeout:       10 * time.Second,
-		WriteTimeout:      scrapeTimeout + 10*time.Second,
-		IdleTimeout:       2 * time.Minute,
+// registerCollectors creates and registers with reg one collector per
+// configured instance, keyed by instance name ("" for the single unnamed
+// instance). Named instances are told apart by an instance_name label on
+// every metric.
+func registerCollectors(reg prometheus.Registerer, cfg *config.Config) (map[string]*collector.AccelCollector, error) {
+	opts, err := collectorOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.VersionInfo {
+		opts = append(opts, collector.WithVersionInfo())
 	}
+	if cfg.TopSessions > 0 {
+		opts = append(opts, collector.WithTopSessions(cfg.TopSessions))
+	}
+	if cfg.PollInterval > 0 {
+		opts = append(opts, collector.WithPollInterval(cfg.PollInterval))
+	}
+	opts = slices.Clip(opts)
+	collectors := make(map[string]*collector.AccelCollector)
+	if len(cfg.Instances) == 0 {
+		source := newSource(cfg)
+		logger := slog.Default().With("target", sourceTarget(source))
+		c := collector.NewAccelCollector(source, cfg.ScrapeTimeout, append(opts, collector.WithLogger(logger))...)
+		reg.MustRegister(c)
+		collectors[""] = c
+	}
+	for _, inst := range cfg.Instances {
+		slog.Info("Scraping instance", "instance", inst.Name, "transport", inst.Transport)
+		source := instanceSource(cfg, inst)
+		logger := slog.Default().With("instance", inst.Name, "target", sourceTarget(source))
+		c := collector.NewAccelCollector(source, cfg.ScrapeTimeout, append(opts, collector.WithLogger(logger))...)
+		prometheus.WrapRegistererWith(prometheus.Labels{"instance_name": inst.Name}, reg).MustRegister(c)
+		collectors[inst.Name] = c
+	}
+	return collectors, nil
+}
+
+// newSource builds the Source of the single, unnamed instance the
+// -accel-stat.file, -accel-cli.* and -accel-cmd.* flags describe.
+func newSource(cfg *config.Config) collector.Source {
+	switch {
+	case cfg.StatFile != "":
+		slog.Info("Reading show stat output from a file", "path", cfg.StatFile)
+		return &collector.FileSource{Path: cfg.StatFile}
+	case cfg.CLIAddress != "":
+		slog.Info("Querying accel-ppp CLI", "address", cfg.CLIAddress)
+		return &collector.TCPSource{Client: &parser.CLIClient{
+			Address:  cfg.CLIAddress,
+			Password: cfg.CLIPassword,
+		}}
+	default:
+		return &collector.ExecSource{Path: cfg.AccelCmdPath}
+	}
+}
+
+// instanceSource builds the Source of a named instance.
+func instanceSource(cfg *config.Config, inst config.Instance) collector.Source {
+	if inst.Transport == "tcp" {
+		return &collector.TCPSource{Client: &parser.CLIClient{
+			Address:  inst.Address(),
+			Password: inst.Password,
+		}}
+	}
+	return &collector.ExecSource{Path: cfg.AccelCmdPath, Args: inst.AccelCmdArgs()}
+}
 
-	log.Fatal(srv.ListenAndServe())
+// sourceTarget describes what a built-in source queries, for log lines. It
+// leaves out accel-cmd arguments, which may hold a password.
+func sourceTarget(source collector.Source) string {
+	switch s := source.(type) {
+	case *collector.ExecSource:
+		return s.Path
+	case *collector.TCPSource:
+		return s.Client.Address
+	case *collector.FileSource:
+		return s.Path
+	}
+	return ""
+}
+
+// collectorOptions translates the collector flags other than
+// -collector.poll-interval, -collector.version and -collector.sessions-top,
+// which only apply to long-lived collectors, into options.
+func collectorOptions(cfg *config.Config) ([]collector.Option, error) {
+	var opts []collector.Option
+	if cfg.UnknownStats {
+		opts = append(opts, collector.WithUnknownStats())
+	}
+	if cfg.StrictParsing {
+		opts = append(opts, collector.WithStrictParsing())
+	}
+	if cfg.Sessions {
+		opts = append(opts, collector.WithSessionMetrics(cfg.SessionLimit))
+	}
+	if cfg.SessionsBy {
+		if err := collector.ValidateSessionGroupBy(cfg.SessionGroupBy); err != nil {
+			return nil, fmt.Errorf("invalid -collector.sessions-by.group-by: %w", err)
+		}
+		opts = append(opts, collector.WithSessionBreakdown(cfg.SessionGroupBy...))
+	}
+	if cfg.SessionUptime {
+		opts = append(opts, collector.WithSessionUptimeHistogram(cfg.UptimeBuckets...))
+	}
+	return opts, nil
+}
+
+// statusHandler serves the status of the single unnamed collector as is, and
+// that of named instances as a JSON object keyed by instance name.
+func statusHandler(collectors map[string]*collector.AccelCollector) http.Handler {
+	if c, ok := collectors[""]; ok && len(collectors) == 1 {
+		return c.StatusHandler()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		status := make(map[string]collector.Status, len(collectors))
+		for name, c := range collectors {
+			status[name] = c.Status()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(status)
+	})
 }
@@ -1,8 +1,18 @@
 package main
 
 import (
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/taihen/accel-exporter/pkg/collector"
+	"github.com/taihen/accel-exporter/pkg/config"
+	"github.com/taihen/accel-exporter/pkg/parser"
 )
 
 // TestVersionInfo pins the format of the build-info string emitted at startup
@@ -17,3 +27,73 @@ func TestVersionInfo(t *testing.T) {
 		}
 	}
 }
+
+// closedAddr returns a loopback address nothing listens on.
+func closedAddr(t *testing.T) (host string, port int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ln.Close()
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// instanceNames returns the instance_name labels of accel_up gathered from g.
+func instanceNames(t *testing.T, g prometheus.Gatherer) []string {
+	t.Helper()
+	mfs, err := g.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var names []string
+	for _, mf := range mfs {
+		if mf.GetName() != "accel_up" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "instance_name" {
+					names = append(names, l.GetValue())
+				}
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// TestRegisterCollectorsInstances checks named instances register side by
+// side, each series labelled with its instance_name, and that /status
+// reports every instance.
+func TestRegisterCollectorsInstances(t *testing.T) {
+	host, port := closedAddr(t)
+	cfg := &config.Config{
+		ScrapeTimeout: time.Second,
+		Instances: []config.Instance{
+			{Name: "vrf1", Transport: "tcp", Host: host, Port: port},
+			{Name: "vrf2", Transport: "tcp", Host: host, Port: port},
+		},
+	}
+	reg := prometheus.NewPedanticRegistry()
+	collectors, err := registerCollectors(reg, cfg)
+	if err != nil {
+		t.Fatalf("registerCollectors: %v", err)
+	}
+
+	names := instanceNames(t, reg)
+	if want := []string{"vrf1", "vrf2"}; !slices.Equal(names, want) {
+		t.Errorf("accel_up instance_name labels = %v, want %v", names, want)
+	}
+
+	rec := httptest.NewRecorder()
+	statusHandler(collectors).ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	var status map[string]collector.Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	if len(status) != 2 || status["vrf1"].LastErrorReason != parser.ReasonConnection {
+		t.Errorf("status = %+v, want both instances with a connection error", status)
+	}
+}
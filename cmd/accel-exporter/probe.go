@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/taihen/accel-exporter/pkg/collector"
+	"github.com/taihen/accel-exporter/pkg/config"
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+// probeHandler serves /probe?target=host:port[&module=name], which scrapes
+// the accel-ppp TCP CLI at target with the credentials and timeout of the
+// named -probe.module, so one exporter can cover many accel-ppp nodes
+// (blackbox_exporter style). Targets the module's pattern does not match are
+// refused, keeping its password from hosts it was not meant for. Every
+// request builds its own collector and registry: probes share no state, so
+// the options that need it (-collector.poll-interval, -collector.sessions-top
+// and -collector.version, whose show version query would otherwise run on
+// every probe) are left out, and show stat is parsed with
+// parser.DefaultProfile.
+func probeHandler(cfg *config.Config) (http.Handler, error) {
+	opts, err := collectorOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	opts = slices.Clip(opts)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		target := query.Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			http.Error(w, "target must be host:port: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		mod, ok := cfg.Module(query.Get("module"))
+		if !ok {
+			http.Error(w, "unknown module "+query.Get("module"), http.StatusBadRequest)
+			return
+		}
+		if !mod.Allows(target) {
+			http.Error(w, "target "+target+" not allowed by module "+mod.Name, http.StatusForbidden)
+			return
+		}
+
+		timeout := mod.Timeout
+		if timeout == 0 {
+			timeout = cfg.ScrapeTimeout
+		}
+		source := &collector.TCPSource{Client: &parser.CLIClient{
+			Address:  target,
+			Password: mod.Password,
+		}}
+		logger := slog.Default().With("target", target, "module", mod.Name)
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(collector.NewAccelCollector(source, timeout, append(opts, collector.WithLogger(logger))...))
+		promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}), nil
+}
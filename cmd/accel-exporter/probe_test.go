@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/taihen/accel-exporter/pkg/config"
+)
+
+// fakeCLI serves accel-ppp's TCP CLI protocol, answering show stat and show
+// version once the client sent password, and returns its address.
+func fakeCLI(t *testing.T, password string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewScanner(conn)
+				if password != "" && (!r.Scan() || r.Text() != password) {
+					_, _ = conn.Write([]byte("authentication failed\n"))
+					return
+				}
+				for r.Scan() {
+					switch r.Text() {
+					case "show stat":
+						_, _ = conn.Write([]byte("uptime: 0.01:00:00\nsessions:\n  active: 42\n"))
+					case "show version":
+						_, _ = conn.Write([]byte("accel-ppp version 1.12.0\n"))
+					case "exit":
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestProbeHandler(t *testing.T) {
+	addr := fakeCLI(t, "s3cret")
+	cfg := &config.Config{
+		ScrapeTimeout: time.Second,
+		VersionInfo:   true,
+		TopSessions:   5,
+		Modules: map[string]config.Module{
+			"bras":  {Name: "bras", Password: "s3cret"},
+			"stale": {Name: "stale", Password: "old"},
+			"dc1":   {Name: "dc1", Password: "s3cret", Targets: regexp.MustCompile(`^10\.1\..*$`)},
+		},
+	}
+	h, err := probeHandler(cfg)
+	if err != nil {
+		t.Fatalf("probeHandler: %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		code     int
+		contains []string
+	}{
+		{"?target=" + addr + "&module=bras", http.StatusOK, []string{
+			"accel_up 1",
+			"accel_sessions_active 42",
+		}},
+		{"?target=" + addr + "&module=stale", http.StatusOK, []string{"accel_up 0", `accel_scrape_failures_total{reason="auth"} 1`}},
+		{"", http.StatusBadRequest, []string{"target parameter is missing"}},
+		{"?target=" + addr + "&module=nope", http.StatusBadRequest, []string{"unknown module"}},
+		{"?target=" + addr + "&module=dc1", http.StatusForbidden, []string{"not allowed by module dc1"}},
+		{"?target=localhost", http.StatusBadRequest, []string{"host:port"}},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/probe"+tt.query, nil))
+		if rec.Code != tt.code {
+			t.Errorf("GET /probe%s: status %d, want %d", tt.query, rec.Code, tt.code)
+		}
+		// Probes never query show version or show sessions for the options
+		// that need state between scrapes.
+		for _, name := range []string{"accel_ppp_version_info", "accel_show_sessions_up"} {
+			if strings.Contains(rec.Body.String(), name) {
+				t.Errorf("GET /probe%s: %s exported", tt.query, name)
+			}
+		}
+		for _, want := range tt.contains {
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("GET /probe%s: body missing %q:\n%s", tt.query, want, rec.Body.String())
+			}
+		}
+	}
+}
+
+// TestProbeDisabled verifies /probe answers 404 unless enabled, so that an
+// exporter set up for local scraping cannot be pointed at arbitrary hosts.
+func TestProbeDisabled(t *testing.T) {
+	addr := fakeCLI(t, "")
+	for _, tt := range []struct {
+		cfg  *config.Config
+		code int
+	}{
+		{&config.Config{ScrapeTimeout: time.Second}, http.StatusNotFound},
+		{&config.Config{ScrapeTimeout: time.Second, EnableProbe: true}, http.StatusOK},
+	} {
+		e, err := newExporter(prometheus.NewRegistry(), tt.cfg, new(slog.LevelVar))
+		if err != nil {
+			t.Fatalf("newExporter: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		e.probeHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/probe?target="+addr, nil))
+		if rec.Code != tt.code {
+			t.Errorf("GET /probe with EnableProbe %v: status %d, want %d", tt.cfg.EnableProbe, rec.Code, tt.code)
+		}
+	}
+}
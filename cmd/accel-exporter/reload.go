@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/taihen/accel-exporter/pkg/collector"
+	"github.com/taihen/accel-exporter/pkg/config"
+)
+
+// exporter serves the collectors built from the current configuration. A
+// reload builds a complete new set and swaps it in at once, so every scrape
+// sees either the old configuration or the new one, never a mix.
+type exporter struct {
+	// mu serialises reloads.
+	mu      sync.Mutex
+	current atomic.Pointer[exporterState]
+
+	// level is the level of the default logger, which reloads update.
+	level *slog.LevelVar
+	// lifecycle enables POST /-/reload and writeTimeout is the HTTP server's
+	// write timeout, both fixed by the startup configuration.
+	lifecycle    bool
+	writeTimeout time.Duration
+
+	reloadSuccess prometheus.Gauge
+	reloadTime    prometheus.Gauge
+}
+
+// exporterState is everything derived from one configuration.
+type exporterState struct {
+	cfg        *config.Config
+	registry   *prometheus.Registry
+	collectors map[string]*collector.AccelCollector
+	status     http.Handler
+	// probe is nil when /probe is disabled (see config.Config.ProbeEnabled).
+	probe http.Handler
+	// stop ends the collectors' background polling.
+	stop context.CancelFunc
+}
+
+// newExporter builds the collectors for cfg and registers the reload metrics
+// with reg. Reloads set level to the reloaded -log.level.
+func newExporter(reg prometheus.Registerer, cfg *config.Config, level *slog.LevelVar) (*exporter, error) {
+	e := &exporter{
+		level:        level,
+		lifecycle:    cfg.EnableLifecycle,
+		writeTimeout: writeTimeout(cfg),
+		reloadSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "accel_exporter_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful.",
+		}),
+		reloadTime: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "accel_exporter_config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful configuration reload.",
+		}),
+	}
+	st, err := newExporterState(cfg)
+	if err != nil {
+		return nil, err
+	}
+	e.swap(st)
+	e.reloadSuccess.Set(1)
+	e.reloadTime.SetToCurrentTime()
+	reg.MustRegister(e.reloadSuccess, e.reloadTime)
+	return e, nil
+}
+
+// newExporterState registers cfg's collectors with a registry of their own.
+func newExporterState(cfg *config.Config) (*exporterState, error) {
+	reg := prometheus.NewRegistry()
+	collectors, err := registerCollectors(reg, cfg)
+	if err != nil {
+		return nil, err
+	}
+	st := &exporterState{
+		cfg:        cfg,
+		registry:   reg,
+		collectors: collectors,
+		status:     statusHandler(collectors),
+	}
+	if cfg.ProbeEnabled() {
+		if st.probe, err = probeHandler(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// swap makes st current, starting its polling and stopping the previous
+// state's.
+func (e *exporter) swap(st *exporterState) {
+	var ctx context.Context
+	ctx, st.stop = context.WithCancel(context.Background())
+	if st.cfg.PollInterval > 0 {
+		slog.Info("Polling accel-ppp", "interval", st.cfg.PollInterval)
+		for _, c := range st.collectors {
+			go c.Poll(ctx)
+		}
+	}
+	if old := e.current.Swap(st); old != nil {
+		old.stop()
+	}
+}
+
+// reload re-reads the configuration and swaps in collectors built from it. On
+// error the current collectors are kept.
+func (e *exporter) reload() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	old := e.current.Load()
+	cfg, err := old.cfg.Reload()
+	var level slog.Level
+	if err == nil {
+		level, err = parseLevel(cfg.LogLevel)
+	}
+	var st *exporterState
+	if err == nil {
+		st, err = newExporterState(cfg)
+	}
+	if err != nil {
+		e.reloadSuccess.Set(0)
+		slog.Error("Error reloading configuration", "err", err)
+		return err
+	}
+	if cfg.ListenAddress != old.cfg.ListenAddress || cfg.MetricsPath != old.cfg.MetricsPath || cfg.LogFormat != old.cfg.LogFormat || cfg.EnableLifecycle != old.cfg.EnableLifecycle {
+		slog.Warn("Changes to the listen address, metrics path, log format and lifecycle API take effect on restart")
+	}
+	if d := writeTimeout(cfg); d > e.writeTimeout {
+		slog.Warn("Scrape timeouts exceed the HTTP write timeout; slow scrapes may be cut off until restart", "write_timeout", e.writeTimeout, "needed", d)
+	}
+	e.level.Set(level)
+	e.swap(st)
+	e.reloadSuccess.Set(1)
+	e.reloadTime.SetToCurrentTime()
+	slog.Info("Reloaded configuration")
+	return nil
+}
+
+// reloadOnSIGHUP reloads the configuration whenever the process receives
+// SIGHUP.
+func (e *exporter) reloadOnSIGHUP() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			_ = e.reload()
+		}
+	}()
+}
+
+// Gather implements prometheus.Gatherer over the current collectors.
+func (e *exporter) Gather() ([]*dto.MetricFamily, error) {
+	return e.current.Load().registry.Gather()
+}
+
+// metricsHandler serves the current collectors' metrics along with those of
+// gatherer, which holds the exporter's own.
+func (e *exporter) metricsHandler(reg prometheus.Registerer, gatherer prometheus.Gatherer) http.Handler {
+	return promhttp.InstrumentMetricHandler(reg,
+		promhttp.HandlerFor(prometheus.Gatherers{gatherer, e}, promhttp.HandlerOpts{}))
+}
+
+// statusHandler serves /status for the current collectors.
+func (e *exporter) statusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.current.Load().status.ServeHTTP(w, r)
+	})
+}
+
+// probeHandler serves /probe with the current modules, or 404 while the
+// current configuration disables it.
+func (e *exporter) probeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probe := e.current.Load().probe
+		if probe == nil {
+			http.Error(w, "/probe is disabled; enable it with -web.enable-probe or a -probe.module", http.StatusNotFound)
+			return
+		}
+		probe.ServeHTTP(w, r)
+	})
+}
+
+// reloadHandler serves POST /-/reload, which, as in Prometheus, is refused
+// unless -web.enable-lifecycle was given at startup.
+func (e *exporter) reloadHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !e.lifecycle {
+			http.Error(w, "lifecycle API is not enabled; start with -web.enable-lifecycle", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST requests allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := e.reload(); err != nil {
+			http.Error(w, "failed to reload config: "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
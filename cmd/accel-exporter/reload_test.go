@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/taihen/accel-exporter/pkg/config"
+)
+
+func TestExporterReload(t *testing.T) {
+	host, port := closedAddr(t)
+	path := filepath.Join(t.TempDir(), "accel-exporter.yml")
+	writeConfig := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	instances := func(names ...string) string {
+		s := "instances:\n"
+		for _, name := range names {
+			s += fmt.Sprintf("  - {name: %s, transport: tcp, host: %s, port: %d}\n", name, host, port)
+		}
+		return s
+	}
+
+	writeConfig(instances("vrf1"))
+	cfg := &config.Config{ConfigFile: path, ScrapeTimeout: time.Second, LogLevel: "info", EnableLifecycle: true}
+	cfg, err := cfg.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	e, err := newExporter(prometheus.NewRegistry(), cfg, new(slog.LevelVar))
+	if err != nil {
+		t.Fatalf("newExporter: %v", err)
+	}
+	if got, want := instanceNames(t, e), []string{"vrf1"}; !slices.Equal(got, want) {
+		t.Errorf("instances before reload = %v, want %v", got, want)
+	}
+
+	post := func() int {
+		rec := httptest.NewRecorder()
+		e.reloadHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+		return rec.Code
+	}
+
+	writeConfig(instances("vrf2", "vrf3") + "log:\n  level: debug\n")
+	if code := post(); code != http.StatusOK {
+		t.Fatalf("POST /-/reload: status %d", code)
+	}
+	if got, want := instanceNames(t, e), []string{"vrf2", "vrf3"}; !slices.Equal(got, want) {
+		t.Errorf("instances after reload = %v, want %v", got, want)
+	}
+	if got := testutil.ToFloat64(e.reloadSuccess); got != 1 {
+		t.Errorf("reload successful = %v, want 1", got)
+	}
+	if got := e.level.Level(); got != slog.LevelDebug {
+		t.Errorf("log level after reload = %v, want DEBUG", got)
+	}
+
+	writeConfig("instances:\n  - {name: vrf4, transport: ssh}\n")
+	if code := post(); code != http.StatusInternalServerError {
+		t.Errorf("POST /-/reload of an invalid file: status %d, want 500", code)
+	}
+	if got := testutil.ToFloat64(e.reloadSuccess); got != 0 {
+		t.Errorf("reload successful = %v after a failed reload, want 0", got)
+	}
+	if got, want := instanceNames(t, e), []string{"vrf2", "vrf3"}; !slices.Equal(got, want) {
+		t.Errorf("instances after a failed reload = %v, want the previous %v", got, want)
+	}
+
+	rec := httptest.NewRecorder()
+	e.reloadHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/reload", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /-/reload: status %d, want 405", rec.Code)
+	}
+
+	// Without -web.enable-lifecycle only SIGHUP reloads.
+	e.lifecycle = false
+	if code := post(); code != http.StatusForbidden {
+		t.Errorf("POST /-/reload without the lifecycle API: status %d, want 403", code)
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	cfg := &config.Config{ScrapeTimeout: 5 * time.Second}
+	if got := writeTimeout(cfg); got != 15*time.Second {
+		t.Errorf("writeTimeout = %s, want 15s", got)
+	}
+	cfg.Modules = map[string]config.Module{"slow": {Name: "slow", Timeout: 30 * time.Second}}
+	if got := writeTimeout(cfg); got != 40*time.Second {
+		t.Errorf("writeTimeout with a 30s module = %s, want 40s", got)
+	}
+}
@@ -3,18 +3,31 @@
 //
 // The collector is stateless: Collect parses a fresh snapshot and emits const
 // metrics built on the fly, so concurrent scrapes (e.g. an HA Prometheus pair)
-// never share mutable metric state. The only persistent metric is the
-// cumulative scrape-failure counter, whose increments are atomic.
+// never share mutable metric state, and overlapping scrapes share one
+// in-flight query rather than each running their own. The only persistent
+// metrics are cumulative counters, whose increments are atomic. A few pieces
+// of state do outlive a scrape, each behind its own mutex: the opt-in top-N
+// session rates, which need the previous session snapshot; the accel-ppp
+// version cache of WithVersionInfo, kept until accel-ppp restarts; and the
+// last-scrape record served as Status.
+//
+// With background polling (WithPollInterval and Poll) the snapshot is taken on
+// a timer instead of per scrape, and every scrape is served the latest one.
 package collector
 
 import (
-	"log"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/taihen/accel-exporter/pkg/parser"
 )
 
+// stateLabels labels per-state breakdowns such as L2TP tunnels and sessions.
+var stateLabels = []string{"state"}
+
 // radiusLabels are the labels attached to every per-RADIUS-server metric.
 var radiusLabels = []string{"server_id", "server_ip"}
 
@@ -24,11 +37,15 @@ func newDesc(name, help string, labels ...string) *prometheus.Desc {
 
 // Descriptors are built once and shared across scrapes (they are immutable).
 var (
-	upDesc      = newDesc("accel_up", "Was the last accel-cmd scrape successful.")
-	uptimeDesc  = newDesc("accel_uptime_seconds", "Uptime of accel-ppp in seconds.")
-	cpuDesc     = newDesc("accel_cpu_usage_percent", "CPU usage percentage.")
-	memRSSDesc  = newDesc("accel_memory_rss_bytes", "RSS memory usage in bytes.")
-	memVirtDesc = newDesc("accel_memory_virtual_bytes", "Virtual memory usage in bytes.")
+	upDesc          = newDesc("accel_up", "Was the last accel-cmd scrape successful.")
+	lastScrapeDesc  = newDesc("accel_last_scrape_timestamp_seconds", "Unix time at which the served snapshot was taken.")
+	snapshotAgeDesc = newDesc("accel_snapshot_age_seconds", "Age of the served snapshot in seconds.")
+	durationDesc    = newDesc("accel_scrape_duration_seconds", "Time taken by the queries behind the served snapshot, in seconds.")
+	phaseDesc       = newDesc("accel_scrape_phase_duration_seconds", "Time taken by each phase of the served show stat query, in seconds.", "phase")
+	uptimeDesc      = newDesc("accel_uptime_seconds", "Uptime of accel-ppp in seconds.")
+	cpuDesc         = newDesc("accel_cpu_usage_percent", "CPU usage percentage.")
+	memRSSDesc      = newDesc("accel_memory_rss_bytes", "RSS memory usage in bytes.")
+	memVirtDesc     = newDesc("accel_memory_virtual_bytes", "Virtual memory usage in bytes.")
 
 	coreMempoolAllocatedDesc = newDesc("accel_core_mempool_allocated_bytes", "Allocated memory pool size.")
 	coreMempoolAvailableDesc = newDesc("accel_core_mempool_available_bytes", "Available memory pool size.")
@@ -57,6 +74,18 @@ var (
 	pppoeSentPADSDesc    = newDesc("accel_pppoe_sent_pads_total", "Total sent PADS packets.")
 	pppoeFilteredDesc    = newDesc("accel_pppoe_filtered_total", "Total filtered PPPoE packets.")
 
+	ipoeStartingDesc      = newDesc("accel_ipoe_starting", "Number of IPoE sessions starting.")
+	ipoeActiveDesc        = newDesc("accel_ipoe_active", "Number of active IPoE sessions.")
+	ipoeDelayedOffersDesc = newDesc("accel_ipoe_delayed_offers", "Number of DHCP offers currently delayed.")
+
+	l2tpTunnelsDesc  = newDesc("accel_l2tp_tunnels", "Number of L2TP tunnels by state.", stateLabels...)
+	l2tpSessionsDesc = newDesc("accel_l2tp_sessions", "Number of L2TP sessions by state.", stateLabels...)
+
+	pptpStartingDesc = newDesc("accel_pptp_starting", "Number of PPTP sessions starting.")
+	pptpActiveDesc   = newDesc("accel_pptp_active", "Number of active PPTP sessions.")
+	sstpStartingDesc = newDesc("accel_sstp_starting", "Number of SSTP sessions starting.")
+	sstpActiveDesc   = newDesc("accel_sstp_active", "Number of active SSTP sessions.")
+
 	radiusStateDesc            = newDesc("accel_radius_state", "State of RADIUS server (1 = active, 0 = inactive).", radiusLabels...)
 	radiusFailCountDesc        = newDesc("accel_radius_fail_count_total", "Total RADIUS server fail count.", radiusLabels...)
 	radiusRequestCountDesc     = newDesc("accel_radius_request_count", "Current RADIUS server request count.", radiusLabels...)
@@ -79,17 +108,22 @@ var (
 	radiusInterimLost1mDesc    = newDesc("accel_radius_interim_lost_1m", "RADIUS interim accounting packets lost in the last 1 minute.", radiusLabels...)
 	radiusInterimAvgTime5mDesc = newDesc("accel_radius_interim_avg_time_5m_seconds", "Average RADIUS interim accounting response time in the last 5 minutes (seconds).", radiusLabels...)
 	radiusInterimAvgTime1mDesc = newDesc("accel_radius_interim_avg_time_1m_seconds", "Average RADIUS interim accounting response time in the last 1 minute (seconds).", radiusLabels...)
+
+	statValueDesc = newDesc("accel_stat_value", "Value of a show stat line the exporter has no dedicated metric for.", "section", "key", "field")
 )
 
 // allDescs lists every descriptor the collector can emit, for Describe.
 var allDescs = []*prometheus.Desc{
-	upDesc, uptimeDesc, cpuDesc, memRSSDesc, memVirtDesc,
+	upDesc, lastScrapeDesc, snapshotAgeDesc, durationDesc, phaseDesc, uptimeDesc, cpuDesc, memRSSDesc, memVirtDesc,
 	coreMempoolAllocatedDesc, coreMempoolAvailableDesc, coreThreadCountDesc, coreThreadActiveDesc,
 	coreContextCountDesc, coreContextSleepingDesc, coreContextPendingDesc,
 	coreMDHandlerCountDesc, coreMDHandlerPendingDesc, coreTimerCountDesc, coreTimerPendingDesc,
 	sessionsStartingDesc, sessionsActiveDesc, sessionsFinishingDesc,
 	pppoeStartingDesc, pppoeActiveDesc, pppoeDelayedPADODesc, pppoeRecvPADIDesc, pppoeDropPADIDesc,
 	pppoeSentPADODesc, pppoeRecvPADRDesc, pppoeRecvPADRDupDesc, pppoeSentPADSDesc, pppoeFilteredDesc,
+	ipoeStartingDesc, ipoeActiveDesc, ipoeDelayedOffersDesc,
+	l2tpTunnelsDesc, l2tpSessionsDesc,
+	pptpStartingDesc, pptpActiveDesc, sstpStartingDesc, sstpActiveDesc,
 	radiusStateDesc, radiusFailCountDesc, radiusRequestCountDesc, radiusQueueLengthDesc,
 	radiusAuthSentDesc, radiusAuthLostTotalDesc, radiusAuthLost5mDesc, radiusAuthLost1mDesc,
 	radiusAuthAvgTime5mDesc, radiusAuthAvgTime1mDesc,
@@ -104,28 +138,105 @@ const DefaultScrapeTimeout = 5 * time.Second
 
 // AccelCollector implements the prometheus.Collector interface
 type AccelCollector struct {
-	accelCmdPath string
-	timeout      time.Duration
+	source  Source
+	timeout time.Duration
+	// logger receives the scrape log lines (see WithLogger).
+	logger *slog.Logger
+	// unknownStats exports lines the parser does not recognise as
+	// accel_stat_value.
+	unknownStats bool
+	// strict fails scrapes whose show stat output has parse warnings.
+	strict bool
+	// version caches the accel-ppp version for accel_ppp_version_info; nil
+	// disables it.
+	version *versionCache
+	// sessionLimit caps per-session series; zero disables them.
+	sessionLimit int
+	// sessionGroupBy and sessionsByDesc configure accel_sessions_by; a nil
+	// desc disables it.
+	sessionGroupBy []string
+	sessionsByDesc *prometheus.Desc
+	// uptimeBuckets are the accel_session_uptime_seconds bounds; nil
+	// disables the histogram.
+	uptimeBuckets []float64
+	// topN sessions by byte rate are exported when positive, with rates
+	// derived from the snapshots kept in rates.
+	topN  int
+	rates *sessionRates
+
+	// pollInterval enables background polling via Poll; cached holds the
+	// latest polled snapshot, served to every scrape while Poll runs.
+	pollInterval time.Duration
+	cached       atomic.Pointer[snapshot]
+
+	// mu guards inflight, the scrape concurrent Collect calls join.
+	mu       sync.Mutex
+	inflight *flight
 
-	// scrapeFailures is the only persistent metric: a cumulative counter whose
-	// Inc is atomic and safe under concurrent scrapes.
-	scrapeFailures prometheus.Counter
+	// scrapeFailures, parseErrors, coalesced and latency are the only
+	// persistent metrics: cumulative counters and a histogram whose updates
+	// are atomic and safe under concurrent scrapes.
+	scrapeFailures *prometheus.CounterVec
+	parseErrors    *prometheus.CounterVec
+	coalesced      prometheus.Counter
+	latency        prometheus.Histogram
+
+	// status backs Status, the last error for the status endpoint.
+	status statusTracker
+}
+
+// Option configures optional AccelCollector behaviour.
+type Option func(*AccelCollector)
+
+// WithLogger sends the collector's log lines to logger instead of
+// slog.Default(). Give it attributes telling the collectors of a process apart,
+// e.g. logger.With("target", address).
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *AccelCollector) { c.logger = logger }
 }
 
-// NewAccelCollector creates a new AccelCollector. A non-positive timeout falls
-// back to DefaultScrapeTimeout.
-func NewAccelCollector(accelCmdPath string, timeout time.Duration) *AccelCollector {
+// WithUnknownStats exports every numeric show stat line the parser has no
+// dedicated metric for as accel_stat_value{section,key,field}, so counters
+// added by newer accel-ppp releases are visible without an exporter release.
+func WithUnknownStats() Option {
+	return func(c *AccelCollector) { c.unknownStats = true }
+}
+
+// NewAccelCollector creates a new AccelCollector that reads snapshots from
+// source. Each fetch is bounded by timeout; a non-positive timeout falls back
+// to DefaultScrapeTimeout.
+func NewAccelCollector(source Source, timeout time.Duration, opts ...Option) *AccelCollector {
 	if timeout <= 0 {
 		timeout = DefaultScrapeTimeout
 	}
-	return &AccelCollector{
-		accelCmdPath: accelCmdPath,
-		timeout:      timeout,
-		scrapeFailures: prometheus.NewCounter(prometheus.CounterOpts{
+	c := &AccelCollector{
+		source:  source,
+		timeout: timeout,
+		logger:  slog.Default(),
+		scrapeFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "accel_scrape_failures_total",
-			Help: "Number of errors while scraping accel-cmd.",
+			Help: "Number of errors while scraping accel-cmd, by reason.",
+		}, []string{"reason"}),
+		parseErrors: newParseErrors(),
+		coalesced: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "accel_scrape_coalesced_total",
+			Help: "Number of scrapes served by joining another scrape's in-flight query.",
 		}),
+		latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "accel_scrape_latency_seconds",
+			Help:    "Distribution of the time taken by queries to accel-ppp, in seconds.",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+	// Every reason is exported from the start, so rate() and alerts see a
+	// zero before the first failure of each kind.
+	for _, reason := range parser.FailureReasons {
+		c.scrapeFailures.WithLabelValues(reason)
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Describe implements the prometheus.Collector interface
@@ -133,25 +244,46 @@ func (c *AccelCollector) Describe(ch chan<- *prometheus.Desc) {
 	for _, d := range allDescs {
 		ch <- d
 	}
+	if c.unknownStats {
+		ch <- statValueDesc
+	}
+	if c.version != nil {
+		ch <- versionInfoDesc
+	}
+	c.describeSessions(ch)
 	c.scrapeFailures.Describe(ch)
+	c.parseErrors.Describe(ch)
+	c.coalesced.Describe(ch)
+	c.latency.Describe(ch)
 }
 
 // Collect implements the prometheus.Collector interface. It builds const
-// metrics from a fresh snapshot, so it holds no mutable state between or during
-// scrapes and is safe to run concurrently.
+// metrics from the current snapshot (a fresh one unless background polling is
+// running), so it holds no mutable state between or during scrapes and is safe
+// to run concurrently.
 func (c *AccelCollector) Collect(ch chan<- prometheus.Metric) {
-	stats, err := parser.CollectStats(c.accelCmdPath, c.timeout)
-	if err != nil {
-		c.scrapeFailures.Inc()
-		ch <- c.scrapeFailures
+	snap := c.snapshot()
+
+	c.scrapeFailures.Collect(ch)
+	c.parseErrors.Collect(ch)
+	ch <- c.coalesced
+	ch <- c.latency
+	ch <- prometheus.MustNewConstMetric(durationDesc, prometheus.GaugeValue, snap.duration.Seconds())
+	ch <- prometheus.MustNewConstMetric(lastScrapeDesc, prometheus.GaugeValue, float64(snap.at.UnixNano())/1e9)
+	ch <- prometheus.MustNewConstMetric(snapshotAgeDesc, prometheus.GaugeValue, time.Since(snap.at).Seconds())
+	if snap.err != nil {
 		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 0)
-		log.Printf("Error collecting stats: %v", err)
 		return
 	}
-
-	ch <- c.scrapeFailures
 	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 1)
 
+	c.collectStats(ch, snap.stats)
+	collectVersion(ch, snap.version)
+	c.collectSessions(ch, snap)
+}
+
+// collectStats emits the show stat metrics.
+func (c *AccelCollector) collectStats(ch chan<- prometheus.Metric, stats *parser.Stats) {
 	gauge := func(d *prometheus.Desc, v float64) {
 		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
 	}
@@ -159,6 +291,12 @@ func (c *AccelCollector) Collect(ch chan<- prometheus.Metric) {
 		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
 	}
 
+	// Timing, when the source measured it
+	if t := stats.Timing; t != (parser.Timing{}) {
+		ch <- prometheus.MustNewConstMetric(phaseDesc, prometheus.GaugeValue, t.Exec.Seconds(), "exec")
+		ch <- prometheus.MustNewConstMetric(phaseDesc, prometheus.GaugeValue, t.Parse.Seconds(), "parse")
+	}
+
 	// General
 	gauge(uptimeDesc, stats.Uptime)
 	gauge(cpuDesc, stats.CPUPercent)
@@ -195,6 +333,26 @@ func (c *AccelCollector) Collect(ch chan<- prometheus.Metric) {
 	counter(pppoeSentPADSDesc, stats.PPPoE.SentPADS)
 	counter(pppoeFilteredDesc, stats.PPPoE.Filtered)
 
+	// IPoE
+	gauge(ipoeStartingDesc, stats.IPoE.Starting)
+	gauge(ipoeActiveDesc, stats.IPoE.Active)
+	gauge(ipoeDelayedOffersDesc, stats.IPoE.DelayedOffers)
+
+	// L2TP
+	byState := func(d *prometheus.Desc, l parser.L2TPCounters) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, l.Starting, "starting")
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, l.Active, "active")
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, l.Finishing, "finishing")
+	}
+	byState(l2tpTunnelsDesc, stats.L2TP.Tunnels)
+	byState(l2tpSessionsDesc, stats.L2TP.Sessions)
+
+	// PPTP / SSTP
+	gauge(pptpStartingDesc, stats.PPTP.Starting)
+	gauge(pptpActiveDesc, stats.PPTP.Active)
+	gauge(sstpStartingDesc, stats.SSTP.Starting)
+	gauge(sstpActiveDesc, stats.SSTP.Active)
+
 	// RADIUS (per server). Absent servers are simply not emitted, so stale
 	// series disappear automatically without any reset bookkeeping.
 	for id, rs := range stats.RadiusServers {
@@ -232,4 +390,17 @@ func (c *AccelCollector) Collect(ch chan<- prometheus.Metric) {
 		rGauge(radiusInterimAvgTime5mDesc, rs.InterimAvgTime5m)
 		rGauge(radiusInterimAvgTime1mDesc, rs.InterimAvgTime1m)
 	}
+	if c.unknownStats {
+		// A repeated line would otherwise yield a duplicate series and fail
+		// the whole scrape; the first occurrence wins.
+		seen := make(map[parser.StatValue]bool, len(stats.Unknown))
+		for _, v := range stats.Unknown {
+			id := parser.StatValue{Section: v.Section, Key: v.Key, Field: v.Field}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			ch <- prometheus.MustNewConstMetric(statValueDesc, prometheus.UntypedValue, v.Value, v.Section, v.Key, v.Field)
+		}
+	}
 }
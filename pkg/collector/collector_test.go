@@ -1,15 +1,18 @@
 package collector
 
 import (
-	"os"
-	"path/filepath"
-	"runtime"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
+	"github.com/taihen/accel-exporter/pkg/parser"
 )
 
 const sampleStat = `uptime: 138.00:05:20
@@ -18,34 +21,52 @@ mem(rss/virt): 12345 / 67890 K
 pppoe:
   active: 90
   recv PADI: 1000
+ipoe:
+  active: 40
+  delayed offers: 6
+l2tp:
+  tunnels:
+    active: 12
+  sessions (control channels):
+    active: 30
+pptp:
+  active: 8
+sstp:
+  active: 5
 radius(1, 10.0.0.1):
   state: active
   auth sent: 500
 `
 
-// fakeCollector returns a collector backed by an executable shell script that
-// prints sampleStat, plus the script path. Skips on windows.
+// stubSource is a Source that parses a fixed `show stat` capture, or fails
+// with err when set, so collector tests need neither accel-ppp nor a shell.
+type stubSource struct {
+	out string
+	err error
+}
+
+func (s stubSource) Fetch(_ context.Context) (*parser.Stats, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return parser.ParseStats(s.out)
+}
+
+// fakeCollector returns a collector backed by a stub source serving sampleStat.
 func fakeCollector(t *testing.T) *AccelCollector {
 	t.Helper()
-	if runtime.GOOS == "windows" {
-		t.Skip("shell-script fake not supported on windows")
-	}
-	path := filepath.Join(t.TempDir(), "accel-cmd")
-	if err := os.WriteFile(path, []byte("#!/bin/sh\ncat <<'EOF'\n"+sampleStat+"EOF\n"), 0o755); err != nil {
-		t.Fatalf("write fake: %v", err)
-	}
-	return NewAccelCollector(path, time.Second)
+	return NewAccelCollector(stubSource{out: sampleStat}, time.Second)
 }
 
 func TestNewAccelCollector(t *testing.T) {
-	if c := NewAccelCollector("accel-cmd", time.Second); c == nil {
+	if c := NewAccelCollector(&ExecSource{Path: "accel-cmd"}, time.Second); c == nil {
 		t.Fatal("NewAccelCollector returned nil")
 	}
 }
 
 // TestNewAccelCollectorDefaultsTimeout guards the non-positive-timeout fallback.
 func TestNewAccelCollectorDefaultsTimeout(t *testing.T) {
-	if c := NewAccelCollector("accel-cmd", 0); c.timeout != DefaultScrapeTimeout {
+	if c := NewAccelCollector(&ExecSource{Path: "accel-cmd"}, 0); c.timeout != DefaultScrapeTimeout {
 		t.Errorf("timeout = %v, want %v", c.timeout, DefaultScrapeTimeout)
 	}
 }
@@ -53,7 +74,7 @@ func TestNewAccelCollectorDefaultsTimeout(t *testing.T) {
 // TestDescribeEmitsDescriptors guards that Describe reports the collector's
 // fixed metrics, so prometheus.MustRegister sees a non-empty, conflict-free set.
 func TestDescribeEmitsDescriptors(t *testing.T) {
-	c := NewAccelCollector("accel-cmd", time.Second)
+	c := NewAccelCollector(&ExecSource{Path: "accel-cmd"}, time.Second)
 	ch := make(chan *prometheus.Desc, 256)
 	c.Describe(ch)
 	close(ch)
@@ -63,8 +84,8 @@ func TestDescribeEmitsDescriptors(t *testing.T) {
 }
 
 // gather collects the registry and returns metric values keyed by family name.
-// Families with labels (e.g. radius_*) are skipped; the scrape-failure path
-// under test emits only the unlabelled accel_up and accel_scrape_failures_total.
+// Families with labels (e.g. radius_* or accel_scrape_failures_total) are
+// skipped.
 func gather(t *testing.T, reg *prometheus.Registry) map[string]float64 {
 	t.Helper()
 	mfs, err := reg.Gather()
@@ -94,14 +115,47 @@ func gather(t *testing.T, reg *prometheus.Registry) map[string]float64 {
 // live accel-ppp, and the one that matters most for alerting.
 func TestCollectScrapeFailure(t *testing.T) {
 	reg := prometheus.NewPedanticRegistry()
-	reg.MustRegister(NewAccelCollector("/nonexistent/accel-cmd-xyz", time.Second))
+	reg.MustRegister(NewAccelCollector(&ExecSource{Path: "/nonexistent/accel-cmd-xyz"}, time.Second))
 
 	vals := gather(t, reg)
 	if up, ok := vals["accel_up"]; !ok || up != 0 {
 		t.Errorf("accel_up = %v (present=%v), want 0", up, ok)
 	}
-	if f, ok := vals["accel_scrape_failures_total"]; !ok || f < 1 {
-		t.Errorf("accel_scrape_failures_total = %v (present=%v), want >= 1", f, ok)
+	failures := map[string]float64{}
+	for _, m := range families(t, reg)["accel_scrape_failures_total"].GetMetric() {
+		failures[labelMap(m)["reason"]] = m.GetCounter().GetValue()
+	}
+	if f := failures[parser.ReasonNotFound]; f < 1 {
+		t.Errorf("accel_scrape_failures_total{reason=not_found} = %v, want >= 1", f)
+	}
+	// The other reasons are exported at zero before their first failure.
+	if len(failures) != len(parser.FailureReasons) || failures[parser.ReasonTimeout] != 0 {
+		t.Errorf("accel_scrape_failures_total = %v, want every reason with only not_found set", failures)
+	}
+}
+
+// TestCollectLogsFailure checks failed scrapes are logged through WithLogger
+// with the logger's attributes and the failure reason as fields.
+func TestCollectLogsFailure(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil)).With("target", "bras1")
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(&ExecSource{Path: "/nonexistent/accel-cmd-xyz"}, time.Second, WithLogger(logger)))
+	gather(t, reg)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("decode log line %q: %v", buf.String(), err)
+	}
+	for key, want := range map[string]any{"level": "WARN", "msg": "Error collecting stats", "target": "bras1", "reason": parser.ReasonNotFound} {
+		if line[key] != want {
+			t.Errorf("log field %s = %v, want %v", key, line[key], want)
+		}
+	}
+	for _, key := range []string{"duration", "err"} {
+		if _, ok := line[key]; !ok {
+			t.Errorf("log line %s lacks %s", buf.String(), key)
+		}
 	}
 }
 
@@ -128,6 +182,25 @@ func TestCollectSuccess(t *testing.T) {
 	if padi := byName["accel_pppoe_recv_padi_total"]; padi == nil || padi.GetType() != dto.MetricType_COUNTER {
 		t.Errorf("accel_pppoe_recv_padi_total type = %v, want COUNTER", padi.GetType())
 	}
+	if ipoe := byName["accel_ipoe_active"]; ipoe == nil || ipoe.GetMetric()[0].GetGauge().GetValue() != 40 {
+		t.Errorf("accel_ipoe_active missing or != 40: %v", ipoe)
+	}
+	if pptp := byName["accel_pptp_active"]; pptp == nil || pptp.GetMetric()[0].GetGauge().GetValue() != 8 {
+		t.Errorf("accel_pptp_active missing or != 8: %v", pptp)
+	}
+	if sstp := byName["accel_sstp_active"]; sstp == nil || sstp.GetMetric()[0].GetGauge().GetValue() != 5 {
+		t.Errorf("accel_sstp_active missing or != 5: %v", sstp)
+	}
+	// L2TP counts are broken down by a state label.
+	if l2tp := byName["accel_l2tp_tunnels"]; l2tp == nil || len(l2tp.GetMetric()) != 3 {
+		t.Errorf("accel_l2tp_tunnels = %v, want starting/active/finishing series", l2tp)
+	} else {
+		for _, m := range l2tp.GetMetric() {
+			if m.GetLabel()[0].GetValue() == "active" && m.GetGauge().GetValue() != 12 {
+				t.Errorf("accel_l2tp_tunnels{state=active} = %v, want 12", m.GetGauge().GetValue())
+			}
+		}
+	}
 	// labelled RADIUS series present with correct labels.
 	state := byName["accel_radius_state"]
 	if state == nil || len(state.GetMetric()) != 1 {
@@ -142,6 +215,133 @@ func TestCollectSuccess(t *testing.T) {
 	}
 }
 
+// TestCollectSourceError verifies any Source failure, not just a failed exec,
+// is reported as accel_up=0.
+func TestCollectSourceError(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(stubSource{err: errors.New("boom")}, time.Second))
+
+	if up := gather(t, reg)["accel_up"]; up != 0 {
+		t.Errorf("accel_up = %v, want 0", up)
+	}
+}
+
+// timedSource is a stubSource reporting a fixed per-phase timing.
+type timedSource struct {
+	stubSource
+	timing parser.Timing
+}
+
+func (s timedSource) Fetch(ctx context.Context) (*parser.Stats, error) {
+	st, err := s.stubSource.Fetch(ctx)
+	if err == nil {
+		st.Timing = s.timing
+	}
+	return st, err
+}
+
+// TestCollectTiming verifies the scrape duration gauge and latency histogram
+// are always exported, and the per-phase breakdown only when the source
+// measured it.
+func TestCollectTiming(t *testing.T) {
+	src := timedSource{
+		stubSource: stubSource{out: sampleStat},
+		timing:     parser.Timing{Exec: 300 * time.Millisecond, Parse: 2 * time.Millisecond},
+	}
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(src, time.Second))
+
+	for range 2 {
+		if _, err := reg.Gather(); err != nil {
+			t.Fatalf("Gather: %v", err)
+		}
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	byName := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		byName[mf.GetName()] = mf
+	}
+
+	if d := byName["accel_scrape_duration_seconds"]; d == nil || d.GetMetric()[0].GetGauge().GetValue() < 0 {
+		t.Errorf("accel_scrape_duration_seconds = %v, want a non-negative gauge", d)
+	}
+	if h := byName["accel_scrape_latency_seconds"]; h == nil || h.GetMetric()[0].GetHistogram().GetSampleCount() != 3 {
+		t.Errorf("accel_scrape_latency_seconds = %v, want 3 observations", h)
+	}
+	phases := map[string]float64{}
+	if p := byName["accel_scrape_phase_duration_seconds"]; p != nil {
+		for _, m := range p.GetMetric() {
+			phases[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+		}
+	}
+	if phases["exec"] != 0.3 || phases["parse"] != 0.002 {
+		t.Errorf("accel_scrape_phase_duration_seconds = %v, want exec=0.3 parse=0.002", phases)
+	}
+
+	// A source that does not measure its phases gets no breakdown.
+	reg = prometheus.NewPedanticRegistry()
+	reg.MustRegister(fakeCollector(t))
+	if mfs, err = reg.Gather(); err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() == "accel_scrape_phase_duration_seconds" {
+			t.Errorf("accel_scrape_phase_duration_seconds exported without source timing: %v", mf)
+		}
+	}
+}
+
+// TestCollectUnknownStats verifies unrecognised lines surface as
+// accel_stat_value only when the option is enabled.
+func TestCollectUnknownStats(t *testing.T) {
+	src := stubSource{out: sampleStat + "newproto:\n  active: 9\n  lost(total/1m): 5 / 1\n"}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(src, time.Second))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() == "accel_stat_value" {
+			t.Fatal("accel_stat_value exported without WithUnknownStats")
+		}
+	}
+
+	reg = prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(src, time.Second, WithUnknownStats()))
+	mfs, err = reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	got := map[string]float64{}
+	for _, mf := range mfs {
+		if mf.GetName() != "accel_stat_value" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			l := map[string]string{}
+			for _, lp := range m.GetLabel() {
+				l[lp.GetName()] = lp.GetValue()
+			}
+			got[l["section"]+"|"+l["key"]+"|"+l["field"]] = m.GetUntyped().GetValue()
+		}
+	}
+	want := map[string]float64{
+		"newproto|active|":    9,
+		"newproto|lost|total": 5,
+		"newproto|lost|1m":    1,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("accel_stat_value{%s} = %v, want %v (all: %v)", k, got[k], v, got)
+		}
+	}
+}
+
 // TestCollectConcurrent runs many overlapping scrapes; with the stateless
 // const-metric design this must be race-free (run with -race) and never panic.
 func TestCollectConcurrent(t *testing.T) {
@@ -0,0 +1,137 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+// snapshot is the result of one round of queries against accel-ppp: show stat
+// and, when a session metric is enabled, show sessions.
+type snapshot struct {
+	at time.Time
+	// duration is how long all of the snapshot's queries took.
+	duration time.Duration
+	stats    *parser.Stats
+	err      error
+	// version is the accel-ppp version, when detection is enabled and
+	// succeeded.
+	version string
+
+	sessions    []parser.Session
+	sessionsErr error
+	// topRates are computed when the snapshot is taken, not when it is
+	// served, so a cached snapshot served twice reports the same rates.
+	topRates []sessionRate
+}
+
+// WithPollInterval makes Poll refresh the snapshot every interval in the
+// background, and every scrape is then served the latest snapshot instead of
+// querying accel-ppp itself. This keeps the CLI load constant however many
+// Prometheus servers scrape the exporter. A non-positive interval disables
+// polling.
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *AccelCollector) { c.pollInterval = interval }
+}
+
+// Poll refreshes the cached snapshot immediately and then every poll interval
+// until ctx is done, after which scrapes query accel-ppp directly again. It
+// returns at once if no interval was configured.
+func (c *AccelCollector) Poll(ctx context.Context) {
+	if c.pollInterval <= 0 {
+		return
+	}
+	defer c.cached.Store(nil)
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		c.cached.Store(c.scrape())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// flight is a scrape in progress that concurrent Collect calls wait on.
+type flight struct {
+	done chan struct{}
+	snap *snapshot
+}
+
+// snapshot returns the latest polled snapshot, or takes a fresh one when
+// polling is not running. Concurrent callers share a single in-flight scrape,
+// so overlapping Prometheus scrapes cost accel-ppp one query, not one each.
+func (c *AccelCollector) snapshot() *snapshot {
+	if snap := c.cached.Load(); snap != nil {
+		return snap
+	}
+
+	c.mu.Lock()
+	if f := c.inflight; f != nil {
+		c.mu.Unlock()
+		c.coalesced.Inc()
+		<-f.done
+		return f.snap
+	}
+	f := &flight{done: make(chan struct{})}
+	c.inflight = f
+	c.mu.Unlock()
+
+	f.snap = c.scrape()
+
+	c.mu.Lock()
+	c.inflight = nil
+	c.mu.Unlock()
+	close(f.done)
+	return f.snap
+}
+
+// scrape queries accel-ppp, all queries together bounded by the collector's
+// timeout, and records how long it took and how it went whether or not it
+// succeeded.
+func (c *AccelCollector) scrape() *snapshot {
+	snap := &snapshot{at: time.Now()}
+	defer func() {
+		snap.duration = time.Since(snap.at)
+		c.latency.Observe(snap.duration.Seconds())
+		c.status.record(snap)
+		if snap.err == nil {
+			c.logger.Debug("Scraped accel-ppp", "duration", snap.duration)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	if c.version != nil {
+		ctx = parser.WithProfile(ctx, parser.ProfileFor(c.version.get(ctx, c)))
+	}
+	snap.stats, snap.err = c.source.Fetch(ctx)
+	if snap.err == nil {
+		snap.err = c.checkWarnings(snap.stats)
+	}
+	if snap.err != nil {
+		snap.stats = nil
+		reason := parser.FailureReason(snap.err)
+		c.scrapeFailures.WithLabelValues(reason).Inc()
+		c.logger.Warn("Error collecting stats", "reason", reason, "duration", time.Since(snap.at), "err", snap.err)
+		return snap
+	}
+
+	if c.version != nil {
+		snap.version = c.version.refresh(ctx, c, snap.stats.Uptime)
+	}
+	if c.sessionsEnabled() {
+		snap.sessions, snap.sessionsErr = c.fetchSessions(ctx)
+		switch {
+		case snap.sessionsErr != nil:
+			c.logger.Warn("Error collecting sessions", "reason", parser.FailureReason(snap.sessionsErr), "err", snap.sessionsErr)
+		case c.topN > 0:
+			snap.topRates = c.rates.update(snap.sessions, time.Now())
+		}
+	}
+	return snap
+}
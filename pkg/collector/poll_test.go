@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+// countingSource counts Fetch calls.
+type countingSource struct {
+	stubSource
+	calls atomic.Int64
+}
+
+func (s *countingSource) Fetch(ctx context.Context) (*parser.Stats, error) {
+	s.calls.Add(1)
+	return s.stubSource.Fetch(ctx)
+}
+
+// TestPollServesCachedSnapshot verifies scrapes do not query the source while
+// polling runs, and expose the snapshot's timestamp and age.
+func TestPollServesCachedSnapshot(t *testing.T) {
+	src := &countingSource{stubSource: stubSource{out: sampleStat}}
+	c := NewAccelCollector(src, time.Second, WithPollInterval(time.Hour))
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Poll(ctx)
+		close(done)
+	}()
+	// Wait for the initial poll.
+	deadline := time.Now().Add(5 * time.Second)
+	for c.cached.Load() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Poll did not take an initial snapshot")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for range 5 {
+		vals := gather(t, reg)
+		if vals["accel_up"] != 1 {
+			t.Errorf("accel_up = %v, want 1", vals["accel_up"])
+		}
+		if vals["accel_last_scrape_timestamp_seconds"] <= 0 {
+			t.Errorf("accel_last_scrape_timestamp_seconds = %v, want > 0", vals["accel_last_scrape_timestamp_seconds"])
+		}
+		if _, ok := vals["accel_snapshot_age_seconds"]; !ok {
+			t.Error("accel_snapshot_age_seconds missing")
+		}
+	}
+	if n := src.calls.Load(); n != 1 {
+		t.Errorf("Fetch called %d times, want 1 (the initial poll)", n)
+	}
+
+	// Once polling stops, scrapes go back to querying the source.
+	cancel()
+	<-done
+	gather(t, reg)
+	if n := src.calls.Load(); n != 2 {
+		t.Errorf("Fetch called %d times after Poll returned, want 2", n)
+	}
+}
+
+// blockingSource blocks every Fetch until release is closed.
+type blockingSource struct {
+	countingSource
+	release chan struct{}
+}
+
+func (s *blockingSource) Fetch(ctx context.Context) (*parser.Stats, error) {
+	<-s.release
+	return s.countingSource.Fetch(ctx)
+}
+
+// TestCollectCoalescesConcurrentScrapes holds the first scrape's query open
+// until every other scrape has joined it, then checks the source was queried
+// once and the joins were counted.
+func TestCollectCoalescesConcurrentScrapes(t *testing.T) {
+	const scrapes = 8
+	src := &blockingSource{
+		countingSource: countingSource{stubSource: stubSource{out: sampleStat}},
+		release:        make(chan struct{}),
+	}
+	c := NewAccelCollector(src, time.Second)
+
+	var wg sync.WaitGroup
+	for range scrapes {
+		wg.Go(func() {
+			ch := make(chan prometheus.Metric, 512)
+			c.Collect(ch)
+		})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(c.coalesced) < scrapes-1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %v scrapes coalesced", testutil.ToFloat64(c.coalesced))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(src.release)
+	wg.Wait()
+
+	if n := src.calls.Load(); n != 1 {
+		t.Errorf("Fetch called %d times, want 1", n)
+	}
+
+	// A scrape after the flight has landed queries afresh.
+	c.Collect(make(chan prometheus.Metric, 512))
+	if n := src.calls.Load(); n != 2 {
+		t.Errorf("Fetch called %d times, want 2", n)
+	}
+}
+
+func TestPollDisabled(t *testing.T) {
+	c := NewAccelCollector(stubSource{out: sampleStat}, time.Second)
+	done := make(chan struct{})
+	go func() {
+		c.Poll(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Poll without an interval did not return")
+	}
+}
@@ -0,0 +1,263 @@
+package collector
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+// DefaultSessionLimit caps per-session series when no limit is configured.
+const DefaultSessionLimit = 1000
+
+// sessionLabels identify a single subscriber session in per-session metrics.
+var sessionLabels = []string{"sid", "ifname", "username", "ip", "type", "calling_sid", "service_name"}
+
+var (
+	showSessionsUpDesc  = newDesc("accel_show_sessions_up", "Was the last show sessions query successful.")
+	sessionRxBytesDesc  = newDesc("accel_session_rx_bytes_total", "Bytes received from the subscriber during the session.", sessionLabels...)
+	sessionTxBytesDesc  = newDesc("accel_session_tx_bytes_total", "Bytes sent to the subscriber during the session.", sessionLabels...)
+	sessionDurationDesc = newDesc("accel_session_duration_seconds", "How long the session has been up, in seconds.", sessionLabels...)
+	sessionsDroppedDesc = newDesc("accel_session_series_dropped", "Sessions left out of per-session metrics by the series limit.")
+	sessionUptimeDesc   = newDesc("accel_session_uptime_seconds", "Distribution of session uptimes, in seconds.")
+)
+
+// DefaultSessionGroupBy is the label set of accel_sessions_by when none is
+// configured.
+var DefaultSessionGroupBy = []string{"type", "state", "service_name", "inbound_if"}
+
+// sessionGroupByColumns maps each label accel_sessions_by can be grouped by to
+// the session field it reads. Only low-cardinality columns are offered; per
+// subscriber breakdowns belong to WithSessionMetrics.
+var sessionGroupByColumns = map[string]func(parser.Session) string{
+	"type":         func(s parser.Session) string { return s.Type },
+	"state":        func(s parser.Session) string { return s.State },
+	"service_name": func(s parser.Session) string { return s.ServiceName },
+	"inbound_if":   func(s parser.Session) string { return s.InboundIf },
+}
+
+// DefaultUptimeBuckets are the accel_session_uptime_seconds bucket bounds when
+// none are configured: 1m, 5m, 15m, 1h, 4h, 1d and 1w. The low end is what
+// reveals reconnect storms.
+var DefaultUptimeBuckets = []float64{60, 300, 900, 3600, 14400, 86400, 604800}
+
+// errNoSessionCommands is returned when session metrics are enabled on a
+// source that only provides show stat.
+var errNoSessionCommands = errors.New("source cannot run show sessions")
+
+// WithSessionMetrics exports byte counters and uptime for individual sessions
+// from `show sessions`. At most limit sessions are exported (ordered by sid,
+// so the selection is stable across scrapes); a non-positive limit falls back
+// to DefaultSessionLimit. Requires a source implementing Commander.
+func WithSessionMetrics(limit int) Option {
+	return func(c *AccelCollector) {
+		if limit <= 0 {
+			limit = DefaultSessionLimit
+		}
+		c.sessionLimit = limit
+	}
+}
+
+// WithSessionBreakdown exports accel_sessions_by, the number of sessions per
+// distinct combination of the groupBy labels (see ValidateSessionGroupBy); an
+// empty groupBy falls back to DefaultSessionGroupBy. Unlike
+// WithSessionMetrics it carries no per-subscriber labels. Requires a source
+// implementing Commander.
+func WithSessionBreakdown(groupBy ...string) Option {
+	return func(c *AccelCollector) {
+		if len(groupBy) == 0 {
+			groupBy = DefaultSessionGroupBy
+		}
+		c.sessionGroupBy = groupBy
+		c.sessionsByDesc = newDesc("accel_sessions_by", "Number of sessions by "+strings.Join(groupBy, ", ")+".", groupBy...)
+	}
+}
+
+// WithSessionUptimeHistogram exports accel_session_uptime_seconds, a histogram
+// of session uptimes with the given upper bucket bounds (DefaultUptimeBuckets
+// when empty). It carries no per-subscriber labels. Requires a source
+// implementing Commander.
+func WithSessionUptimeHistogram(buckets ...float64) Option {
+	return func(c *AccelCollector) {
+		if len(buckets) == 0 {
+			buckets = DefaultUptimeBuckets
+		}
+		c.uptimeBuckets = slices.Compact(slices.Sorted(slices.Values(buckets)))
+	}
+}
+
+// ValidateSessionGroupBy reports an error if groupBy names a label
+// WithSessionBreakdown cannot group by, or names one twice.
+func ValidateSessionGroupBy(groupBy []string) error {
+	seen := make(map[string]bool, len(groupBy))
+	for _, col := range groupBy {
+		if _, ok := sessionGroupByColumns[col]; !ok {
+			valid := slices.Sorted(maps.Keys(sessionGroupByColumns))
+			return fmt.Errorf("unknown session group-by column %q (valid: %s)", col, strings.Join(valid, ", "))
+		}
+		if seen[col] {
+			return fmt.Errorf("duplicate session group-by column %q", col)
+		}
+		seen[col] = true
+	}
+	return nil
+}
+
+// sessionsEnabled reports whether any metric needs `show sessions`.
+func (c *AccelCollector) sessionsEnabled() bool {
+	return c.sessionLimit > 0 || c.sessionsByDesc != nil || c.uptimeBuckets != nil || c.topN > 0
+}
+
+// describeSessions sends the descriptors of the enabled session metrics.
+func (c *AccelCollector) describeSessions(ch chan<- *prometheus.Desc) {
+	if !c.sessionsEnabled() {
+		return
+	}
+	ch <- showSessionsUpDesc
+	if c.sessionLimit > 0 {
+		ch <- sessionRxBytesDesc
+		ch <- sessionTxBytesDesc
+		ch <- sessionDurationDesc
+		ch <- sessionsDroppedDesc
+	}
+	if c.sessionsByDesc != nil {
+		ch <- c.sessionsByDesc
+	}
+	if c.uptimeBuckets != nil {
+		ch <- sessionUptimeDesc
+	}
+	if c.topN > 0 {
+		ch <- sessionTopRxDesc
+		ch <- sessionTopTxDesc
+	}
+}
+
+// sessionColumns returns the `show sessions` columns the enabled metrics
+// read. service-name is only asked for when a metric carries it, since
+// accel-ppp without the pppoe module rejects it.
+func (c *AccelCollector) sessionColumns() []string {
+	columns := parser.SessionColumns
+	if c.sessionLimit > 0 || slices.Contains(c.sessionGroupBy, "service_name") {
+		columns = append(slices.Clip(columns), parser.ServiceNameColumn)
+	}
+	return columns
+}
+
+// fetchSessions runs `show sessions` through the source under ctx, which
+// carries the deadline of the whole scrape. Malformed rows and values are
+// logged once per call, however many there are.
+func (c *AccelCollector) fetchSessions(ctx context.Context) ([]parser.Session, error) {
+	cmd, ok := c.source.(Commander)
+	if !ok {
+		return nil, errNoSessionCommands
+	}
+	out, err := cmd.Run(ctx, parser.SessionsArgs(c.sessionColumns())...)
+	if err != nil {
+		return nil, err
+	}
+	sessions, problems, err := parser.ParseSessionsReport(string(out))
+	if problems != (parser.SessionProblems{}) {
+		c.logger.Warn("Malformed show sessions output", "skipped_rows", problems.SkippedRows, "malformed_values", problems.MalformedValues)
+	}
+	return sessions, err
+}
+
+// collectSessions emits the enabled session metrics from snap. A failed query
+// only drops the session metrics (reported by accel_show_sessions_up); the
+// show stat metrics are unaffected.
+func (c *AccelCollector) collectSessions(ch chan<- prometheus.Metric, snap *snapshot) {
+	if !c.sessionsEnabled() {
+		return
+	}
+	if snap.sessionsErr != nil {
+		ch <- prometheus.MustNewConstMetric(showSessionsUpDesc, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(showSessionsUpDesc, prometheus.GaugeValue, 1)
+
+	if c.sessionLimit > 0 {
+		c.collectSessionSeries(ch, snap.sessions)
+	}
+	if c.sessionsByDesc != nil {
+		c.collectSessionBreakdown(ch, snap.sessions)
+	}
+	if c.uptimeBuckets != nil {
+		c.collectUptimeHistogram(ch, snap.sessions)
+	}
+	if c.topN > 0 {
+		c.collectTopSessions(ch, snap.topRates)
+	}
+}
+
+// collectSessionBreakdown emits one accel_sessions_by series per distinct
+// combination of the group-by label values.
+func (c *AccelCollector) collectSessionBreakdown(ch chan<- prometheus.Metric, sessions []parser.Session) {
+	// Label values are joined with a separator that cannot appear in them
+	// to form the map key.
+	const sep = "\xff"
+	counts := make(map[string]float64)
+	for _, s := range sessions {
+		values := make([]string, len(c.sessionGroupBy))
+		for i, col := range c.sessionGroupBy {
+			values[i] = sessionGroupByColumns[col](s)
+		}
+		counts[strings.Join(values, sep)]++
+	}
+	for key, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.sessionsByDesc, prometheus.GaugeValue, n, strings.Split(key, sep)...)
+	}
+}
+
+// collectSessionSeries emits per-session series for up to sessionLimit
+// sessions. Sessions whose labels collide with an earlier one (e.g. two rows
+// sharing an empty sid) are skipped, since a duplicate series fails the scrape.
+func (c *AccelCollector) collectSessionSeries(ch chan<- prometheus.Metric, sessions []parser.Session) {
+	sorted := slices.Clone(sessions)
+	slices.SortFunc(sorted, func(a, b parser.Session) int {
+		return cmp.Or(cmp.Compare(a.SID, b.SID), cmp.Compare(a.IfName, b.IfName))
+	})
+
+	seen := make(map[[7]string]bool, min(len(sorted), c.sessionLimit))
+	for _, s := range sorted {
+		if len(seen) == c.sessionLimit {
+			break
+		}
+		labels := [7]string{s.SID, s.IfName, s.Username, s.IP, s.Type, s.CallingSID, s.ServiceName}
+		if seen[labels] {
+			continue
+		}
+		seen[labels] = true
+		ch <- prometheus.MustNewConstMetric(sessionRxBytesDesc, prometheus.CounterValue, s.RxBytes, labels[:]...)
+		ch <- prometheus.MustNewConstMetric(sessionTxBytesDesc, prometheus.CounterValue, s.TxBytes, labels[:]...)
+		ch <- prometheus.MustNewConstMetric(sessionDurationDesc, prometheus.GaugeValue, s.Uptime, labels[:]...)
+	}
+	ch <- prometheus.MustNewConstMetric(sessionsDroppedDesc, prometheus.GaugeValue, float64(len(sorted)-len(seen)))
+}
+
+// collectUptimeHistogram emits the session uptime distribution as a const
+// histogram, computed from scratch on every scrape.
+func (c *AccelCollector) collectUptimeHistogram(ch chan<- prometheus.Metric, sessions []parser.Session) {
+	// Every bound needs an entry, even an empty one, to be exposed.
+	buckets := make(map[float64]uint64, len(c.uptimeBuckets))
+	for _, le := range c.uptimeBuckets {
+		buckets[le] = 0
+	}
+	var sum float64
+	for _, s := range sessions {
+		sum += s.Uptime
+		// Bucket counts are cumulative: a session falls in every bucket whose
+		// bound is at or above its uptime.
+		for _, le := range c.uptimeBuckets {
+			if s.Uptime <= le {
+				buckets[le]++
+			}
+		}
+	}
+	ch <- prometheus.MustNewConstHistogram(sessionUptimeDesc, uint64(len(sessions)), sum, buckets)
+}
@@ -0,0 +1,279 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const sampleSessions = ` sid | ifname | inbound-if | username |    ip    | type  | state  |  uptime  | rx-bytes-raw | tx-bytes-raw | calling-sid | service-name
+-----+--------+------------+----------+----------+-------+--------+----------+--------------+--------------+-------------+-------------
+ s2  | ppp1   | eth0.100   | bob      | 10.0.0.3 | pppoe | active | 00:10:00 | 300          | 400          | aa:02       | internet
+ s1  | ppp0   | eth0.100   | alice    | 10.0.0.2 | pppoe | active | 01:00:00 | 100          | 200          | aa:01       | internet
+ s3  | ipoe0  | eth0.200   | carol    | 10.0.0.4 | ipoe  | active | 00:00:30 | 500          | 600          | aa:03       |
+`
+
+// commandSource is a stub Source that also implements Commander, serving
+// canned output per command (keyed by the first two args).
+type commandSource struct {
+	stubSource
+	outputs map[string]string
+	err     error
+}
+
+func (s commandSource) Run(_ context.Context, args ...string) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []byte(s.outputs[strings.Join(args[:min(2, len(args))], " ")]), nil
+}
+
+func newCommandSource() commandSource {
+	return commandSource{
+		stubSource: stubSource{out: sampleStat},
+		outputs:    map[string]string{"show sessions": sampleSessions},
+	}
+}
+
+// families gathers reg and indexes the result by metric family name.
+func families(t *testing.T, reg *prometheus.Registry) map[string]*dto.MetricFamily {
+	t.Helper()
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	out := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		out[mf.GetName()] = mf
+	}
+	return out
+}
+
+// labelMap flattens a metric's label pairs.
+func labelMap(m *dto.Metric) map[string]string {
+	out := map[string]string{}
+	for _, l := range m.GetLabel() {
+		out[l.GetName()] = l.GetValue()
+	}
+	return out
+}
+
+func TestCollectSessionMetrics(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(newCommandSource(), time.Second, WithSessionMetrics(10)))
+	byName := families(t, reg)
+
+	if up := byName["accel_show_sessions_up"]; up == nil || up.GetMetric()[0].GetGauge().GetValue() != 1 {
+		t.Errorf("accel_show_sessions_up missing or != 1: %v", up)
+	}
+	rx := byName["accel_session_rx_bytes_total"]
+	if rx == nil || len(rx.GetMetric()) != 3 {
+		t.Fatalf("accel_session_rx_bytes_total = %v, want 3 series", rx)
+	}
+	for _, m := range rx.GetMetric() {
+		l := labelMap(m)
+		if l["sid"] == "s1" && (l["username"] != "alice" || l["ip"] != "10.0.0.2" || m.GetCounter().GetValue() != 100) {
+			t.Errorf("session s1 rx = %v %v", l, m.GetCounter().GetValue())
+		}
+	}
+	if d := byName["accel_session_series_dropped"]; d == nil || d.GetMetric()[0].GetGauge().GetValue() != 0 {
+		t.Errorf("accel_session_series_dropped = %v, want 0", d)
+	}
+}
+
+// TestCollectSessionMetricsLimit verifies the series cap keeps the lowest sids
+// and reports how many sessions were left out.
+func TestCollectSessionMetricsLimit(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(newCommandSource(), time.Second, WithSessionMetrics(2)))
+	byName := families(t, reg)
+
+	dur := byName["accel_session_duration_seconds"]
+	if dur == nil || len(dur.GetMetric()) != 2 {
+		t.Fatalf("accel_session_duration_seconds = %v, want 2 series", dur)
+	}
+	for _, m := range dur.GetMetric() {
+		if sid := labelMap(m)["sid"]; sid == "s3" {
+			t.Errorf("session s3 exported despite the limit")
+		}
+	}
+	if d := byName["accel_session_series_dropped"]; d == nil || d.GetMetric()[0].GetGauge().GetValue() != 1 {
+		t.Errorf("accel_session_series_dropped = %v, want 1", d)
+	}
+}
+
+// TestCollectSessionsUnsupportedSource verifies a source without Commander
+// reports accel_show_sessions_up=0 but still serves show stat metrics.
+func TestCollectSessionsUnsupportedSource(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(stubSource{out: sampleStat}, time.Second, WithSessionMetrics(10)))
+	vals := gather(t, reg)
+	if vals["accel_show_sessions_up"] != 0 {
+		t.Errorf("accel_show_sessions_up = %v, want 0", vals["accel_show_sessions_up"])
+	}
+	if vals["accel_up"] != 1 {
+		t.Errorf("accel_up = %v, want 1", vals["accel_up"])
+	}
+}
+
+func TestCollectSessionsError(t *testing.T) {
+	src := newCommandSource()
+	src.err = errors.New("boom")
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(src, time.Second, WithSessionMetrics(10)))
+	if up := gather(t, reg)["accel_show_sessions_up"]; up != 0 {
+		t.Errorf("accel_show_sessions_up = %v, want 0", up)
+	}
+}
+
+func TestCollectSessionBreakdown(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(newCommandSource(), time.Second, WithSessionBreakdown()))
+	byName := families(t, reg)
+
+	by := byName["accel_sessions_by"]
+	if by == nil {
+		t.Fatal("accel_sessions_by missing")
+	}
+	got := map[string]float64{}
+	for _, m := range by.GetMetric() {
+		l := labelMap(m)
+		got[l["type"]+"/"+l["state"]+"/"+l["service_name"]+"/"+l["inbound_if"]] = m.GetGauge().GetValue()
+	}
+	want := map[string]float64{
+		"pppoe/active/internet/eth0.100": 2,
+		"ipoe/active//eth0.200":          1,
+	}
+	if len(got) != len(want) {
+		t.Errorf("accel_sessions_by = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("accel_sessions_by{%s} = %v, want %v", k, got[k], v)
+		}
+	}
+	// Breakdown alone must not turn on per-session series.
+	if _, ok := byName["accel_session_rx_bytes_total"]; ok {
+		t.Error("per-session series exported without WithSessionMetrics")
+	}
+}
+
+func TestCollectSessionBreakdownCustomGroupBy(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(newCommandSource(), time.Second, WithSessionBreakdown("type")))
+	by := families(t, reg)["accel_sessions_by"]
+	if by == nil || len(by.GetMetric()) != 2 {
+		t.Fatalf("accel_sessions_by = %v, want 2 series", by)
+	}
+	for _, m := range by.GetMetric() {
+		l := labelMap(m)
+		if len(l) != 1 {
+			t.Errorf("labels = %v, want only type", l)
+		}
+		if l["type"] == "pppoe" && m.GetGauge().GetValue() != 2 {
+			t.Errorf("accel_sessions_by{type=pppoe} = %v, want 2", m.GetGauge().GetValue())
+		}
+	}
+}
+
+func TestCollectSessionUptimeHistogram(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	// Unsorted, duplicated bounds are normalised.
+	reg.MustRegister(NewAccelCollector(newCommandSource(), time.Second, WithSessionUptimeHistogram(3600, 60, 60)))
+	hf := families(t, reg)["accel_session_uptime_seconds"]
+	if hf == nil || hf.GetType() != dto.MetricType_HISTOGRAM {
+		t.Fatalf("accel_session_uptime_seconds = %v, want a histogram", hf)
+	}
+	h := hf.GetMetric()[0].GetHistogram()
+	// Sample uptimes: 600s, 3600s, 30s.
+	if h.GetSampleCount() != 3 || h.GetSampleSum() != 4230 {
+		t.Errorf("count/sum = %d/%v, want 3/4230", h.GetSampleCount(), h.GetSampleSum())
+	}
+	want := map[float64]uint64{60: 1, 3600: 3}
+	if len(h.GetBucket()) != len(want) {
+		t.Fatalf("buckets = %v, want %v", h.GetBucket(), want)
+	}
+	for _, b := range h.GetBucket() {
+		if want[b.GetUpperBound()] != b.GetCumulativeCount() {
+			t.Errorf("bucket le=%v = %d, want %d", b.GetUpperBound(), b.GetCumulativeCount(), want[b.GetUpperBound()])
+		}
+	}
+}
+
+// TestCollectSessionUptimeHistogramEmpty guards that every bucket is exposed
+// even when no session falls into it.
+func TestCollectSessionUptimeHistogramEmpty(t *testing.T) {
+	src := newCommandSource()
+	src.outputs["show sessions"] = " sid | ifname | uptime\n-----+--------+-------\n"
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(src, time.Second, WithSessionUptimeHistogram()))
+	hf := families(t, reg)["accel_session_uptime_seconds"]
+	if hf == nil {
+		t.Fatal("accel_session_uptime_seconds missing")
+	}
+	if n := len(hf.GetMetric()[0].GetHistogram().GetBucket()); n != len(DefaultUptimeBuckets) {
+		t.Errorf("buckets = %d, want %d", n, len(DefaultUptimeBuckets))
+	}
+}
+
+// TestSessionColumns verifies service-name, which accel-ppp rejects without
+// its pppoe module, is only requested by metrics that carry it.
+func TestSessionColumns(t *testing.T) {
+	tests := []struct {
+		opt  Option
+		want bool
+	}{
+		{WithSessionUptimeHistogram(), false},
+		{WithTopSessions(5), false},
+		{WithSessionBreakdown("type", "state"), false},
+		{WithSessionBreakdown(), true},
+		{WithSessionMetrics(10), true},
+	}
+	for i, tt := range tests {
+		c := NewAccelCollector(newCommandSource(), time.Second, tt.opt)
+		if got := slices.Contains(c.sessionColumns(), "service-name"); got != tt.want {
+			t.Errorf("case %d: service-name requested = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+// TestCollectSessionsRejectedColumn verifies an error message in place of the
+// table fails the session metrics instead of reporting zero sessions.
+func TestCollectSessionsRejectedColumn(t *testing.T) {
+	src := newCommandSource()
+	src.outputs["show sessions"] = "unknown column service-name\n"
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(src, time.Second, WithSessionBreakdown()))
+	fams := families(t, reg)
+	if up := fams["accel_show_sessions_up"].GetMetric()[0].GetGauge().GetValue(); up != 0 {
+		t.Errorf("accel_show_sessions_up = %v, want 0", up)
+	}
+	if fams["accel_sessions_by"] != nil {
+		t.Error("accel_sessions_by exported from an error message")
+	}
+}
+
+func TestValidateSessionGroupBy(t *testing.T) {
+	if err := ValidateSessionGroupBy(DefaultSessionGroupBy); err != nil {
+		t.Errorf("default group-by rejected: %v", err)
+	}
+	for _, bad := range [][]string{{"username"}, {"type", "type"}, {"bogus"}} {
+		if err := ValidateSessionGroupBy(bad); err == nil {
+			t.Errorf("ValidateSessionGroupBy(%v) = nil, want error", bad)
+		}
+	}
+}
+
+func TestSessionsDisabledByDefault(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(newCommandSource(), time.Second))
+	if _, ok := families(t, reg)["accel_show_sessions_up"]; ok {
+		t.Error("session metrics exported without WithSessionMetrics")
+	}
+}
@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+// Source produces a `show stat` snapshot for the collector. Implementations
+// must honour ctx, which carries the scrape deadline, and be safe for
+// concurrent use since overlapping scrapes call Fetch in parallel. ctx also
+// carries the parser profile for the detected accel-ppp version, which
+// implementations should parse with (see parser.ProfileFromContext). Filling in
+// the returned Stats.Timing is optional; the per-phase timing metrics are
+// omitted when it is zero.
+type Source interface {
+	Fetch(ctx context.Context) (*parser.Stats, error)
+}
+
+// Commander is implemented by sources that can run arbitrary accel-ppp CLI
+// commands besides `show stat`. Optional metrics that need other commands
+// (e.g. `show sessions`) are only available from such sources.
+type Commander interface {
+	Run(ctx context.Context, args ...string) ([]byte, error)
+}
+
+// parseTimed parses a show stat output whose query started at start with the
+// profile carried by ctx, and records how long the query and the parse took
+// in the result's Timing.
+func parseTimed(ctx context.Context, start time.Time, out []byte) (*parser.Stats, error) {
+	parseStart := time.Now()
+	stats, err := parser.ParseStatsProfile(string(out), parser.ProfileFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	stats.Timing = parser.Timing{Exec: parseStart.Sub(start), Parse: time.Since(parseStart)}
+	return stats, nil
+}
+
+// ExecSource runs the accel-cmd binary at Path.
+type ExecSource struct {
+	Path string
+	// Args are placed before every command, e.g. "-H", "10.0.0.1", "-p",
+	// "2002" to reach an instance other than the default one.
+	Args []string
+}
+
+// Fetch implements Source.
+func (s *ExecSource) Fetch(ctx context.Context) (*parser.Stats, error) {
+	start := time.Now()
+	out, err := s.Run(ctx, "show", "stat")
+	if err != nil {
+		return nil, err
+	}
+	return parseTimed(ctx, start, out)
+}
+
+// Run implements Commander.
+func (s *ExecSource) Run(ctx context.Context, args ...string) ([]byte, error) {
+	return parser.RunAccelCmd(ctx, s.Path, append(slices.Clone(s.Args), args...)...)
+}
+
+// TCPSource queries accel-ppp's TCP CLI directly through Client.
+type TCPSource struct {
+	Client *parser.CLIClient
+}
+
+// Fetch implements Source.
+func (s *TCPSource) Fetch(ctx context.Context) (*parser.Stats, error) {
+	start := time.Now()
+	out, err := s.Run(ctx, "show", "stat")
+	if err != nil {
+		return nil, err
+	}
+	return parseTimed(ctx, start, out)
+}
+
+// Run implements Commander.
+func (s *TCPSource) Run(ctx context.Context, args ...string) ([]byte, error) {
+	return s.Client.Run(ctx, args...)
+}
+
+// FileSource reads a previously captured `show stat` output from Path on every
+// fetch. It suits tests and setups where another process dumps the output.
+type FileSource struct {
+	Path string
+}
+
+// Fetch implements Source.
+func (s *FileSource) Fetch(ctx context.Context) (*parser.Stats, error) {
+	start := time.Now()
+	out, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	return parseTimed(ctx, start, out)
+}
@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+func TestExecSourceError(t *testing.T) {
+	s := &ExecSource{Path: "/nonexistent/accel-cmd-xyz"}
+	if _, err := s.Fetch(context.Background()); err == nil {
+		t.Fatal("Fetch: want exec error, got nil")
+	}
+}
+
+// TestExecSourceArgs checks Args precede the command, which is how named
+// instances point accel-cmd at their own CLI port.
+func TestExecSourceArgs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell-script fake not supported on windows")
+	}
+	path := filepath.Join(t.TempDir(), "accel-cmd")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho \"$@\"\n"), 0o755); err != nil {
+		t.Fatalf("write fake: %v", err)
+	}
+	s := &ExecSource{Path: path, Args: []string{"-p", "2002"}}
+	out, err := s.Run(context.Background(), "show", "stat")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "-p 2002 show stat" {
+		t.Errorf("accel-cmd args = %q, want %q", got, "-p 2002 show stat")
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stat.txt")
+	if err := os.WriteFile(path, []byte(sampleStat), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	st, err := (&FileSource{Path: path}).Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if st.PPPoE.RecvPADI != 1000 {
+		t.Errorf("PPPoE.RecvPADI = %v, want 1000", st.PPPoE.RecvPADI)
+	}
+
+	// The profile carried by ctx decides how the output is read.
+	legacy := filepath.Join(t.TempDir(), "legacy.txt")
+	if err := os.WriteFile(legacy, []byte("uptime: 0.01:00:00\nl2tp:\n  sessions:\n    active: 7\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for version, want := range map[string]float64{"1.11.0": 7, "1.12.0": 0} {
+		ctx := parser.WithProfile(context.Background(), parser.ProfileFor(version))
+		if st, err = (&FileSource{Path: legacy}).Fetch(ctx); err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		if st.L2TP.Sessions.Active != want {
+			t.Errorf("L2TP.Sessions.Active with the %s profile = %v, want %v", version, st.L2TP.Sessions.Active, want)
+		}
+	}
+
+	if _, err := (&FileSource{Path: filepath.Join(t.TempDir(), "missing")}).Fetch(context.Background()); err == nil {
+		t.Error("Fetch of a missing file: want error, got nil")
+	}
+}
+
+// TestTCPSource drives a TCP stand-in for accel-ppp's CLI that replays
+// sampleStat, and checks the collector accepts it like any other source.
+func TestTCPSource(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// Drain the request up to "exit" before replying, as accel-ppp does.
+			sc := bufio.NewScanner(conn)
+			for sc.Scan() {
+				if sc.Text() == "exit" {
+					break
+				}
+			}
+			_, _ = conn.Write([]byte(sampleStat))
+			conn.Close()
+		}
+	}()
+
+	src := &TCPSource{Client: &parser.CLIClient{Address: ln.Addr().String()}}
+	st, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if st.RadiusServers["1"].IP != "10.0.0.1" {
+		t.Errorf("radius IP = %q, want 10.0.0.1", st.RadiusServers["1"].IP)
+	}
+
+	if c := NewAccelCollector(src, time.Second); c == nil {
+		t.Fatal("NewAccelCollector returned nil")
+	}
+}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+// Status summarises the collector's recent queries to accel-ppp, for on-call
+// diagnosis without shell access to the host.
+type Status struct {
+	// LastScrape is when the latest query started, successful or not.
+	LastScrape time.Time `json:"last_scrape,omitzero"`
+	// LastSuccess is when the latest successful query started.
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	// LastError is the message of the latest failed show stat query,
+	// including accel-cmd's stderr when it exited non-zero. It is kept
+	// after later queries succeed; compare LastErrorTime with LastSuccess.
+	LastError string `json:"last_error,omitempty"`
+	// LastErrorReason classifies LastError (see parser.FailureReason).
+	LastErrorReason string    `json:"last_error_reason,omitempty"`
+	LastErrorTime   time.Time `json:"last_error_time,omitzero"`
+	// LastSessionsError is the message of the latest failed show sessions
+	// query, when a session metric is enabled.
+	LastSessionsError     string    `json:"last_sessions_error,omitempty"`
+	LastSessionsErrorTime time.Time `json:"last_sessions_error_time,omitzero"`
+}
+
+// statusTracker records Status as scrapes complete. Overlapping scrapes can
+// finish concurrently, hence the mutex.
+type statusTracker struct {
+	mu     sync.Mutex
+	status Status
+}
+
+// record folds snap into the status.
+func (t *statusTracker) record(snap *snapshot) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.status.LastScrape = snap.at
+	if snap.err != nil {
+		t.status.LastError = snap.err.Error()
+		t.status.LastErrorReason = parser.FailureReason(snap.err)
+		t.status.LastErrorTime = snap.at
+		return
+	}
+	t.status.LastSuccess = snap.at
+	if snap.sessionsErr != nil {
+		t.status.LastSessionsError = snap.sessionsErr.Error()
+		t.status.LastSessionsErrorTime = snap.at
+	}
+}
+
+// Status returns a summary of the collector's recent queries.
+func (c *AccelCollector) Status() Status {
+	c.status.mu.Lock()
+	defer c.status.mu.Unlock()
+	return c.status.status
+}
+
+// StatusHandler serves Status as JSON.
+func (c *AccelCollector) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(c.Status())
+	})
+}
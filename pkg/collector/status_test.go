@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+func TestStatusRecordsLastError(t *testing.T) {
+	src := &stubSource{err: &parser.ExitError{Code: 1, Stderr: "connect: Connection refused\n"}}
+	c := NewAccelCollector(src, time.Second)
+	c.Collect(make(chan prometheus.Metric, 512))
+
+	st := c.Status()
+	if st.LastError != "accel-cmd exited with status 1: connect: Connection refused" {
+		t.Errorf("LastError = %q, want the exit status with stderr", st.LastError)
+	}
+	if st.LastErrorReason != parser.ReasonExitStatus || st.LastErrorTime.IsZero() || !st.LastSuccess.IsZero() {
+		t.Errorf("Status = %+v, want an exit_status failure and no success", st)
+	}
+
+	// A later success keeps the error for diagnosis but records the success.
+	src.err = nil
+	src.out = sampleStat
+	c.Collect(make(chan prometheus.Metric, 512))
+	st = c.Status()
+	if st.LastError == "" || st.LastSuccess.Before(st.LastErrorTime) {
+		t.Errorf("Status = %+v, want the old error and a newer success", st)
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	c := NewAccelCollector(stubSource{err: errors.New("boom")}, time.Second)
+	c.Collect(make(chan prometheus.Metric, 512))
+
+	rec := httptest.NewRecorder()
+	c.StatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	if got["last_error"] != "boom" || got["last_error_reason"] != parser.ReasonOther {
+		t.Errorf("status = %v, want last_error boom with reason other", got)
+	}
+	if _, ok := got["last_success"]; ok {
+		t.Errorf("status = %v, want no last_success before any success", got)
+	}
+}
@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+// topLabels identify a session in the top-N metrics.
+var topLabels = []string{"username", "ifname", "ip"}
+
+var (
+	sessionTopRxDesc = newDesc("accel_session_top_rx_bytes_per_second", "Receive rate of the sessions with the highest receive rate.", topLabels...)
+	sessionTopTxDesc = newDesc("accel_session_top_tx_bytes_per_second", "Transmit rate of the sessions with the highest transmit rate.", topLabels...)
+)
+
+// WithTopSessions exports the receive and transmit rates of the n busiest
+// sessions, computed from the byte counters of consecutive `show sessions`
+// snapshots. Only 2n series exist at any time however many sessions the node
+// has. Requires a source implementing Commander.
+func WithTopSessions(n int) Option {
+	return func(c *AccelCollector) {
+		if n > 0 {
+			c.topN = n
+			c.rates = &sessionRates{}
+		}
+	}
+}
+
+// sessionRate is a session with its byte rates since the previous snapshot.
+type sessionRate struct {
+	parser.Session
+	rx, tx float64
+}
+
+// sessionRates remembers the previous snapshot's byte counters so rates can
+// be derived. It is the collector's only per-scrape state, guarded by mu
+// because overlapping scrapes update it concurrently.
+type sessionRates struct {
+	mu   sync.Mutex
+	prev map[string][2]float64 // session key -> rx, tx bytes
+	at   time.Time
+}
+
+// sessionKey identifies a session across snapshots. The sid is unique per
+// session; ifname and username are a fallback for sources that omit it.
+func sessionKey(s parser.Session) string {
+	if s.SID != "" {
+		return s.SID
+	}
+	return s.IfName + "\xff" + s.Username
+}
+
+// update records sessions as the new snapshot taken at now and returns the
+// rates of sessions present in both snapshots. The first snapshot yields no
+// rates, nor do sessions whose counters went backwards.
+func (r *sessionRates) update(sessions []parser.Session, now time.Time) []sessionRate {
+	cur := make(map[string][2]float64, len(sessions))
+	for _, s := range sessions {
+		cur[sessionKey(s)] = [2]float64{s.RxBytes, s.TxBytes}
+	}
+
+	r.mu.Lock()
+	prev, at := r.prev, r.at
+	r.prev, r.at = cur, now
+	r.mu.Unlock()
+
+	elapsed := now.Sub(at).Seconds()
+	if prev == nil || elapsed <= 0 {
+		return nil
+	}
+	var out []sessionRate
+	for _, s := range sessions {
+		p, ok := prev[sessionKey(s)]
+		if !ok || s.RxBytes < p[0] || s.TxBytes < p[1] {
+			continue
+		}
+		out = append(out, sessionRate{
+			Session: s,
+			rx:      (s.RxBytes - p[0]) / elapsed,
+			tx:      (s.TxBytes - p[1]) / elapsed,
+		})
+	}
+	return out
+}
+
+// collectTopSessions emits the topN sessions by receive and by transmit rate.
+func (c *AccelCollector) collectTopSessions(ch chan<- prometheus.Metric, rates []sessionRate) {
+	emitTop(ch, sessionTopRxDesc, rates, c.topN, func(r sessionRate) float64 { return r.rx })
+	emitTop(ch, sessionTopTxDesc, rates, c.topN, func(r sessionRate) float64 { return r.tx })
+}
+
+// emitTop sorts rates by value, highest first, and emits up to n of them.
+// Sessions whose labels repeat an earlier one are skipped, since a duplicate
+// series fails the scrape.
+func emitTop(ch chan<- prometheus.Metric, d *prometheus.Desc, rates []sessionRate, n int, value func(sessionRate) float64) {
+	sorted := slices.Clone(rates)
+	slices.SortFunc(sorted, func(a, b sessionRate) int {
+		return cmp.Or(cmp.Compare(value(b), value(a)), cmp.Compare(a.SID, b.SID))
+	})
+	seen := make(map[[3]string]bool, n)
+	for _, r := range sorted {
+		if len(seen) == n {
+			break
+		}
+		labels := [3]string{r.Username, r.IfName, r.IP}
+		if seen[labels] {
+			continue
+		}
+		seen[labels] = true
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, value(r), labels[:]...)
+	}
+}
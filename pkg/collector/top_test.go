@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+func TestSessionRatesUpdate(t *testing.T) {
+	var r sessionRates
+	t0 := time.Unix(1000, 0)
+	first := []parser.Session{
+		{SID: "a", RxBytes: 100, TxBytes: 1000},
+		{SID: "b", RxBytes: 500, TxBytes: 500},
+	}
+	if got := r.update(first, t0); got != nil {
+		t.Fatalf("first snapshot rates = %v, want none", got)
+	}
+
+	second := []parser.Session{
+		{SID: "a", RxBytes: 1100, TxBytes: 1000}, // +1000 rx over 10s
+		{SID: "b", RxBytes: 0, TxBytes: 0},       // counters went backwards
+		{SID: "c", RxBytes: 9999, TxBytes: 9999}, // new session
+	}
+	got := r.update(second, t0.Add(10*time.Second))
+	if len(got) != 1 || got[0].SID != "a" {
+		t.Fatalf("rates = %+v, want only session a", got)
+	}
+	if got[0].rx != 100 || got[0].tx != 0 {
+		t.Errorf("session a rx/tx = %v/%v, want 100/0", got[0].rx, got[0].tx)
+	}
+}
+
+func TestEmitTop(t *testing.T) {
+	rates := []sessionRate{
+		{Session: parser.Session{SID: "1", Username: "a"}, rx: 10},
+		{Session: parser.Session{SID: "2", Username: "b"}, rx: 30},
+		{Session: parser.Session{SID: "3", Username: "c"}, rx: 20},
+		{Session: parser.Session{SID: "4", Username: "b"}, rx: 25}, // duplicate labels of sid 2
+	}
+	ch := make(chan prometheus.Metric, len(rates))
+	emitTop(ch, sessionTopRxDesc, rates, 2, func(r sessionRate) float64 { return r.rx })
+	close(ch)
+
+	var got []string
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		got = append(got, labelMap(&pb)["username"])
+	}
+	// Highest first; sid 4 shares b's labels and is dropped, so c is second.
+	if want := []string{"b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("emitted usernames %v, want %v", got, want)
+	}
+}
+
+// TestCollectTopSessions scrapes twice with growing counters; only the second
+// scrape can report rates, and only for the top N sessions.
+func TestCollectTopSessions(t *testing.T) {
+	src := newCommandSource()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(src, time.Second, WithTopSessions(1)))
+
+	if _, ok := families(t, reg)["accel_session_top_rx_bytes_per_second"]; ok {
+		t.Error("top rates exported on the first scrape, before any previous snapshot")
+	}
+
+	// alice (s1) receives the most since the previous snapshot; carol (s3)
+	// transmits the most.
+	r := strings.NewReplacer("| 100 ", "| 900000 ", "| 600 ", "| 700000 ")
+	src.outputs["show sessions"] = r.Replace(sampleSessions)
+	byName := families(t, reg)
+
+	for name, user := range map[string]string{
+		"accel_session_top_rx_bytes_per_second": "alice",
+		"accel_session_top_tx_bytes_per_second": "carol",
+	} {
+		mf := byName[name]
+		if mf == nil || len(mf.GetMetric()) != 1 {
+			t.Fatalf("%s = %v, want 1 series", name, mf)
+		}
+		m := mf.GetMetric()[0]
+		if l := labelMap(m); l["username"] != user {
+			t.Errorf("%s username = %q, want %q", name, l["username"], user)
+		}
+		if m.GetGauge().GetValue() <= 0 {
+			t.Errorf("%s = %v, want > 0", name, m.GetGauge().GetValue())
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+var versionInfoDesc = newDesc("accel_ppp_version_info", "A metric with a constant '1' value labeled by the version of accel-ppp.", "version")
+
+// WithVersionInfo exports accel_ppp_version_info from `show version`, and
+// parses show stat with the parser profile for that version (see
+// parser.ProfileFor). The version is queried once and cached until accel-ppp's
+// uptime goes backwards, i.e. until it restarts (possibly into a new release),
+// so it costs one extra query per accel-ppp restart rather than one per
+// scrape; failed queries are retried with backoff. Requires a source
+// implementing Commander; other sources simply never report it and parse with
+// parser.DefaultProfile.
+func WithVersionInfo() Option {
+	return func(c *AccelCollector) { c.version = &versionCache{} }
+}
+
+// The delay before retrying a failed show version query doubles with each
+// consecutive failure, from versionRetryMin up to versionRetryMax, so a node
+// that cannot answer it is not asked again on every scrape.
+const (
+	versionRetryMin = 30 * time.Second
+	versionRetryMax = 10 * time.Minute
+)
+
+// versionCache holds the last detected accel-ppp version and the uptime seen
+// at the last scrape, which reveals restarts. Overlapping scrapes update it
+// concurrently, hence the mutex; it is not held while querying, and scrapes
+// overlapping a query use the cached version rather than wait for it.
+type versionCache struct {
+	mu       sync.Mutex
+	version  string
+	uptime   float64
+	querying bool
+	// failures counts consecutive failed queries, retryAt is when the next
+	// one may run.
+	failures int
+	retryAt  time.Time
+}
+
+// get returns the cached version, querying it through c's source first if
+// none is cached, so the parser profile can be picked before show stat runs.
+// ctx is the scrape's, whose deadline the query shares.
+func (v *versionCache) get(ctx context.Context, c *AccelCollector) string {
+	v.mu.Lock()
+	start := v.version == "" && v.startQuery(c)
+	v.mu.Unlock()
+	if start {
+		v.query(ctx, c)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.version
+}
+
+// refresh records the uptime of the latest show stat and returns the
+// version, re-querying it when uptime went backwards, i.e. accel-ppp
+// restarted since the previous scrape. A version that could not be queried
+// is left to get, which retries it with backoff.
+func (v *versionCache) refresh(ctx context.Context, c *AccelCollector, uptime float64) string {
+	v.mu.Lock()
+	restarted := uptime < v.uptime
+	v.uptime = uptime
+	if restarted {
+		v.version, v.failures, v.retryAt = "", 0, time.Time{}
+	}
+	start := restarted && v.startQuery(c)
+	v.mu.Unlock()
+	if start {
+		v.query(ctx, c)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.version
+}
+
+// startQuery reports whether a query should run now and, if so, marks one as
+// running. v.mu must be held.
+func (v *versionCache) startQuery(c *AccelCollector) bool {
+	if _, ok := c.source.(Commander); !ok || v.querying || time.Now().Before(v.retryAt) {
+		return false
+	}
+	v.querying = true
+	return true
+}
+
+// query runs show version through c's source and stores the result. A
+// failure is logged and schedules the next attempt. v.mu must not be held.
+func (v *versionCache) query(ctx context.Context, c *AccelCollector) {
+	var version string
+	out, err := c.source.(Commander).Run(ctx, parser.VersionArgs...)
+	if err == nil {
+		version, err = parser.ParseVersion(string(out))
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.querying = false
+	if err != nil {
+		v.failures++
+		delay := versionRetryMin
+		for i := 1; i < v.failures && delay < versionRetryMax; i++ {
+			delay *= 2
+		}
+		delay = min(delay, versionRetryMax)
+		v.retryAt = time.Now().Add(delay)
+		c.logger.Warn("Error detecting accel-ppp version", "reason", parser.FailureReason(err), "retry_in", delay, "err", err)
+		return
+	}
+	v.version, v.failures, v.retryAt = version, 0, time.Time{}
+	c.logger.Info("Detected accel-ppp version", "version", version, "profile", parser.ProfileFor(version).Name)
+}
+
+// collectVersion emits accel_ppp_version_info when the version is known.
+func collectVersion(ch chan<- prometheus.Metric, version string) {
+	if version != "" {
+		ch <- prometheus.MustNewConstMetric(versionInfoDesc, prometheus.GaugeValue, 1, version)
+	}
+}
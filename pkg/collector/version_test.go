@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+// versionSource reports a configurable uptime and version, or fails show
+// version with err. It counts the show version queries it serves and records
+// the parser profile it was last asked to parse with and the deadlines of
+// its last calls.
+type versionSource struct {
+	uptime  string
+	version string
+	err     error
+	queries int
+	profile string
+
+	fetchDeadline, runDeadline time.Time
+}
+
+func (s *versionSource) Fetch(ctx context.Context) (*parser.Stats, error) {
+	s.fetchDeadline, _ = ctx.Deadline()
+	p := parser.ProfileFromContext(ctx)
+	s.profile = p.Name
+	return parser.ParseStatsProfile("uptime: "+s.uptime+"\n", p)
+}
+
+func (s *versionSource) Run(ctx context.Context, _ ...string) ([]byte, error) {
+	s.runDeadline, _ = ctx.Deadline()
+	s.queries++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []byte(s.version + "\n"), nil
+}
+
+func TestCollectVersionInfo(t *testing.T) {
+	src := &versionSource{uptime: "1.00:00:00", version: "1.12.0"}
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(src, time.Second, WithVersionInfo()))
+
+	version := func() string {
+		t.Helper()
+		fam := families(t, reg)["accel_ppp_version_info"]
+		if fam == nil {
+			return ""
+		}
+		return labelMap(fam.GetMetric()[0])["version"]
+	}
+
+	if got := version(); got != "1.12.0" {
+		t.Errorf("version = %q, want 1.12.0", got)
+	}
+	// The version is known before show stat runs, so even the first scrape
+	// parses with its profile.
+	if src.profile != "1.12" {
+		t.Errorf("parsed with profile %q, want 1.12", src.profile)
+	}
+	// show version eats into the scrape's deadline rather than adding its own.
+	if src.runDeadline.IsZero() || !src.runDeadline.Equal(src.fetchDeadline) {
+		t.Errorf("show version deadline %v, want the show stat deadline %v", src.runDeadline, src.fetchDeadline)
+	}
+	// The cached version is served while uptime keeps growing.
+	src.uptime, src.version = "1.00:01:00", "1.13.0"
+	if got := version(); got != "1.12.0" || src.queries != 1 {
+		t.Errorf("version = %q after %d queries, want cached 1.12.0 after 1", got, src.queries)
+	}
+	// An uptime reset means accel-ppp restarted, possibly upgraded.
+	src.uptime = "0.00:00:05"
+	if got := version(); got != "1.13.0" || src.queries != 2 {
+		t.Errorf("version = %q after %d queries, want 1.13.0 after 2", got, src.queries)
+	}
+}
+
+// TestCollectVersionInfoRetry verifies a failing show version query is not
+// repeated on every scrape, and is retried once the backoff has passed.
+func TestCollectVersionInfoRetry(t *testing.T) {
+	src := &versionSource{uptime: "1.00:00:00", err: errors.New("invalid command")}
+	c := NewAccelCollector(src, time.Second, WithVersionInfo())
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+
+	for range 3 {
+		fams := families(t, reg)
+		if fams["accel_ppp_version_info"] != nil {
+			t.Error("accel_ppp_version_info exported without a version")
+		}
+		if up := fams["accel_up"].GetMetric()[0].GetGauge().GetValue(); up != 1 {
+			t.Errorf("accel_up = %v, want 1 despite show version failing", up)
+		}
+	}
+	if src.queries != 1 {
+		t.Errorf("show version queried %d times in 3 scrapes, want 1 before the retry delay", src.queries)
+	}
+	if d := time.Until(c.version.retryAt); d <= 0 || d > versionRetryMin {
+		t.Errorf("retry in %v, want within %v", d, versionRetryMin)
+	}
+
+	// Once the delay has passed the query is retried.
+	c.version.retryAt = time.Time{}
+	src.err, src.version = nil, "1.12.0"
+	fam := families(t, reg)["accel_ppp_version_info"]
+	if fam == nil || labelMap(fam.GetMetric()[0])["version"] != "1.12.0" || src.queries != 2 {
+		t.Errorf("after the retry delay: version_info %v after %d queries, want 1.12.0 after 2", fam, src.queries)
+	}
+}
+
+func TestCollectVersionInfoUnsupportedSource(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(stubSource{out: sampleStat}, time.Second, WithVersionInfo()))
+
+	fams := families(t, reg)
+	if fams["accel_ppp_version_info"] != nil {
+		t.Error("accel_ppp_version_info exported by a source that cannot run show version")
+	}
+	if up := fams["accel_up"].GetMetric()[0].GetGauge().GetValue(); up != 1 {
+		t.Errorf("accel_up = %v, want 1", up)
+	}
+}
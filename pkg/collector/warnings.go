@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+// WithStrictParsing fails the scrape (accel_up 0, reason "parse") when show
+// stat has any value the parser recognised but could not parse, instead of
+// exporting it as zero. Use it to catch accel-ppp format changes as outages
+// rather than as silently wrong numbers.
+func WithStrictParsing() Option {
+	return func(c *AccelCollector) { c.strict = true }
+}
+
+// newParseErrors returns the accel_parse_errors_total counter.
+func newParseErrors() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "accel_parse_errors_total",
+		Help: "Number of show stat values that could not be parsed, by section and key.",
+	}, []string{"section", "key"})
+}
+
+// checkWarnings logs and counts the parse warnings in stats and, in strict
+// mode, turns them into a scrape error.
+func (c *AccelCollector) checkWarnings(stats *parser.Stats) error {
+	for _, w := range stats.Warnings {
+		c.logger.Warn("Malformed show stat value", "section", w.Section, "key", w.Key, "value", w.Value)
+		c.parseErrors.WithLabelValues(w.Section, w.Key).Inc()
+	}
+	if !c.strict || len(stats.Warnings) == 0 {
+		return nil
+	}
+	var err error = stats.Warnings[0]
+	if n := len(stats.Warnings); n > 1 {
+		err = fmt.Errorf("%w (and %d more)", err, n-1)
+	}
+	return &parser.ParseError{Err: err}
+}
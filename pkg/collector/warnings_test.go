@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/taihen/accel-exporter/pkg/parser"
+)
+
+// malformedStat is sampleStat with one unparseable counter.
+const malformedStat = sampleStat + "sessions:\n  active: lots\n"
+
+func TestCollectParseErrors(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(stubSource{out: malformedStat}, time.Second))
+
+	// Lenient by default: the scrape succeeds and the bad value is counted.
+	if up := gather(t, reg)["accel_up"]; up != 1 {
+		t.Errorf("accel_up = %v, want 1", up)
+	}
+	fam := families(t, reg)["accel_parse_errors_total"]
+	if fam == nil || len(fam.GetMetric()) != 1 {
+		t.Fatalf("accel_parse_errors_total = %v, want one series", fam)
+	}
+	m := fam.GetMetric()[0]
+	if l := labelMap(m); l["section"] != "sessions" || l["key"] != "active" {
+		t.Errorf("accel_parse_errors_total labels = %v, want section=sessions key=active", l)
+	}
+	// Two scrapes so far, each counting the warning once.
+	if v := m.GetCounter().GetValue(); v != 2 {
+		t.Errorf("accel_parse_errors_total = %v, want 2", v)
+	}
+}
+
+func TestCollectStrictParsing(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	c := NewAccelCollector(stubSource{out: malformedStat}, time.Second, WithStrictParsing())
+	reg.MustRegister(c)
+
+	if up := gather(t, reg)["accel_up"]; up != 0 {
+		t.Errorf("accel_up = %v, want 0 in strict mode", up)
+	}
+	if st := c.Status(); st.LastErrorReason != parser.ReasonParse {
+		t.Errorf("LastErrorReason = %q, want %q (error %q)", st.LastErrorReason, parser.ReasonParse, st.LastError)
+	}
+
+	// Well-formed output still scrapes in strict mode.
+	reg = prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewAccelCollector(stubSource{out: sampleStat}, time.Second, WithStrictParsing()))
+	if up := gather(t, reg)["accel_up"]; up != 1 {
+		t.Errorf("accel_up = %v, want 1 for well-formed output", up)
+	}
+}
@@ -1,39 +1,205 @@
 // Package config resolves the exporter's runtime configuration from command
-// line flags and environment variables.
+// line flags, an optional YAML file and environment variables.
 package config
 
 import (
 	"flag"
 	"fmt"
+	"maps"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Config holds the exporter configuration
 type Config struct {
+	// ConfigFile is the YAML file providing defaults for the flags.
+	ConfigFile    string
 	ListenAddress string
 	MetricsPath   string
 	AccelCmdPath  string
-	LogLevel      string
+	CLIAddress    string
+	CLIPassword   string
+	StatFile      string
+	UnknownStats  bool
+	// StrictParsing fails scrapes with malformed show stat values.
+	StrictParsing bool
+	// VersionInfo exports accel_ppp_version_info.
+	VersionInfo  bool
+	Sessions     bool
+	SessionLimit int
+	SessionsBy   bool
+	// SessionGroupBy lists the accel_sessions_by labels.
+	SessionGroupBy []string
+	SessionUptime  bool
+	UptimeBuckets  []float64
+	// TopSessions is how many of the busiest sessions to export; 0 disables.
+	TopSessions int
+	// Instances are the named accel-pppd instances to scrape; when empty the
+	// single instance configured by the accel-cmd/accel-cli flags is.
+	Instances []Instance
+	// Modules are the /probe modules by name (see Module).
+	Modules map[string]Module
+	// EnableProbe serves /probe even without modules (see ProbeEnabled).
+	EnableProbe bool
+	// EnableLifecycle allows reloading through POST /-/reload.
+	EnableLifecycle bool
+	// PollInterval enables background polling when positive.
+	PollInterval time.Duration
+	LogLevel     string
+	// LogFormat is "logfmt" or "json".
+	LogFormat     string
 	ScrapeTimeout time.Duration
+
+	// flags holds the configuration as the command line and environment
+	// left it, before the file is applied, and set the flags they gave
+	// explicitly; Reload starts over from them.
+	flags *Config
+	set   map[string]bool
 }
 
 // NewConfig creates a new configuration from command line flags
 func NewConfig() *Config {
-	cfg := &Config{}
+	cfg := &Config{Modules: map[string]Module{}}
 
+	flag.StringVar(&cfg.ConfigFile, "config.file", "", "YAML file setting any of these options; flags given on the command line override it")
 	flag.StringVar(&cfg.ListenAddress, "web.listen-address", ":9101", "Address to listen on for web interface and telemetry")
 	flag.StringVar(&cfg.MetricsPath, "web.metrics-path", "/metrics", "Path under which to expose metrics")
+	flag.BoolVar(&cfg.EnableProbe, "web.enable-probe", false, "Serve /probe, which scrapes any accel-ppp CLI the request names; implied by -probe.module")
+	flag.BoolVar(&cfg.EnableLifecycle, "web.enable-lifecycle", false, "Reload the configuration on POST /-/reload")
 	flag.StringVar(&cfg.AccelCmdPath, "accel-cmd.path", "accel-cmd", "Path to accel-cmd binary")
+	flag.StringVar(&cfg.CLIAddress, "accel-cli.address", "", "Address (host:port) of accel-ppp's TCP CLI; when set it is queried directly instead of running accel-cmd")
+	flag.StringVar(&cfg.CLIPassword, "accel-cli.password", "", "Password for accel-ppp's TCP CLI")
+	flag.Var((*instanceList)(&cfg.Instances), "instance", "Scrape a named accel-pppd instance, labelled instance_name: comma-separated name=,transport=exec|tcp,host=,port=,password=,args= (repeatable)")
+	flag.Var(moduleMap(cfg.Modules), "probe.module", "Define a /probe module: comma-separated name=,password=,timeout=,targets= (repeatable; name=default applies when the request names no module; targets= is a regexp the host:port must match)")
+	flag.StringVar(&cfg.StatFile, "accel-stat.file", "", "Read show stat output from this file instead of querying accel-ppp (for testing or externally fed setups)")
+	flag.BoolVar(&cfg.UnknownStats, "collector.unknown-stats", false, "Export show stat lines without a dedicated metric as accel_stat_value")
+	flag.BoolVar(&cfg.StrictParsing, "collector.strict-parsing", false, "Fail the scrape (accel_up 0) when a show stat value cannot be parsed instead of exporting it as 0")
+	flag.BoolVar(&cfg.VersionInfo, "collector.version", true, "Export accel_ppp_version_info from show version, queried once per accel-ppp restart")
+	flag.BoolVar(&cfg.Sessions, "collector.sessions", false, "Export per-session metrics from show sessions")
+	flag.IntVar(&cfg.SessionLimit, "collector.sessions.limit", 0, "Maximum number of sessions exported by -collector.sessions (0 means the collector's default of 1000)")
+	flag.BoolVar(&cfg.SessionsBy, "collector.sessions-by", false, "Export session counts grouped by -collector.sessions-by.group-by from show sessions")
+	groupBy := flag.String("collector.sessions-by.group-by", "", "Comma-separated labels for accel_sessions_by out of type, state, service_name, inbound_if (empty means all four)")
+	flag.BoolVar(&cfg.SessionUptime, "collector.sessions-uptime", false, "Export a histogram of session uptimes from show sessions")
+	flag.Var((*floatList)(&cfg.UptimeBuckets), "collector.sessions-uptime.buckets", "Comma-separated upper bounds, in seconds, of the session uptime histogram buckets (empty means the collector's default of 1m, 5m, 15m, 1h, 4h, 1d and 1w)")
+	flag.IntVar(&cfg.TopSessions, "collector.sessions-top", 0, "Export the receive/transmit rates of this many busiest sessions (0 disables)")
+	flag.DurationVar(&cfg.PollInterval, "collector.poll-interval", 0, "Query accel-ppp in the background at this interval and serve every scrape from the cached snapshot (0 queries on every scrape)")
 	flag.StringVar(&cfg.LogLevel, "log.level", "info", "Log level (debug, info, warn, error)")
+	flag.StringVar(&cfg.LogFormat, "log.format", "logfmt", "Log format (logfmt, json)")
 	flag.DurationVar(&cfg.ScrapeTimeout, "accel-cmd.timeout", 5*time.Second, "Maximum time to wait for accel-cmd to return")
 
+	flag.Usage = usage
 	flag.Parse()
+	if err := applyEnv(flag.CommandLine); err != nil {
+		fmt.Fprintf(flag.CommandLine.Output(), "invalid environment: %v\n", err)
+		os.Exit(2)
+	}
+
+	cfg.SessionGroupBy = splitList(*groupBy)
+
+	cfg.set = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { cfg.set[f.Name] = true })
+	cfg.flags = cfg.clone()
+	if err := cfg.load(); err != nil {
+		fmt.Fprintf(flag.CommandLine.Output(), "invalid -config.file: %v\n", err)
+		os.Exit(2)
+	}
+	return cfg
+}
 
-	// Also check environment variables
+// Reload returns a new configuration from the command line and environment
+// NewConfig parsed and the current contents of the config file, for applying
+// configuration changes without a restart. c is left untouched. A Config not
+// made by NewConfig stands for its own command line.
+func (c *Config) Reload() (*Config, error) {
+	flags := c.flags
+	if flags == nil {
+		flags = c
+	}
+	cfg := flags.clone()
+	cfg.flags, cfg.set = flags, c.set
+	if err := cfg.load(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// load applies the config file, if any, under the command line and
+// environment values, then the legacy ACCEL_EXPORTER_PORT.
+func (c *Config) load() error {
+	if c.ConfigFile != "" {
+		if err := c.applyFile(c.ConfigFile, c.set); err != nil {
+			return err
+		}
+	}
+
+	// ACCEL_EXPORTER_PORT predates the per-flag variables and still wins
+	// over every other source, but only replaces the port.
 	if envPort := os.Getenv("ACCEL_EXPORTER_PORT"); envPort != "" {
-		cfg.ListenAddress = fmt.Sprintf(":%s", envPort)
+		host, _, err := net.SplitHostPort(c.ListenAddress)
+		if err != nil {
+			host = ""
+		}
+		c.ListenAddress = net.JoinHostPort(host, envPort)
+	}
+	return nil
+}
+
+// usage prints the flags and how else they can be set.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage of %s:\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprintf(out, "\nEvery flag can also be set in the environment as %s<NAME>, e.g. %s for -web.listen-address\n"+
+		"(repeatable flags take ;-separated values), or in the -config.file YAML file.\n"+
+		"The command line takes precedence over the environment, which takes precedence over the file.\n",
+		EnvPrefix, EnvName("web.listen-address"))
+}
+
+// clone returns a copy of c that load can modify without affecting c. Slices
+// are shared, since load replaces rather than modifies them.
+func (c *Config) clone() *Config {
+	cfg := *c
+	cfg.Modules = maps.Clone(c.Modules)
+	return &cfg
+}
+
+// splitList splits a comma-separated flag value, dropping empty elements.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// floatList is a flag.Value holding a comma-separated list of numbers.
+type floatList []float64
+
+func (l *floatList) String() string {
+	parts := make([]string, len(*l))
+	for i, f := range *l {
+		parts[i] = strconv.FormatFloat(f, 'g', -1, 64)
 	}
+	return strings.Join(parts, ",")
+}
 
-	return cfg
+func (l *floatList) Set(s string) error {
+	var out []float64
+	for _, v := range splitList(s) {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		out = append(out, f)
+	}
+	if len(out) == 0 {
+		return fmt.Errorf("empty list")
+	}
+	*l = out
+	return nil
 }
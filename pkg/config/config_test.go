@@ -3,6 +3,7 @@ package config
 import (
 	"flag"
 	"os"
+	"slices"
 	"testing"
 )
 
@@ -38,6 +39,30 @@ func TestNewConfigDefaults(t *testing.T) {
 		if cfg.LogLevel != "info" {
 			t.Errorf("LogLevel = %q, want info", cfg.LogLevel)
 		}
+		if cfg.LogFormat != "logfmt" {
+			t.Errorf("LogFormat = %q, want logfmt", cfg.LogFormat)
+		}
+		// Session options left empty get the collector's defaults.
+		if cfg.SessionGroupBy != nil || cfg.UptimeBuckets != nil || cfg.SessionLimit != 0 {
+			t.Errorf("SessionGroupBy, UptimeBuckets, SessionLimit = %v, %v, %d, want empty for the collector's defaults",
+				cfg.SessionGroupBy, cfg.UptimeBuckets, cfg.SessionLimit)
+		}
+		if !cfg.VersionInfo {
+			t.Error("VersionInfo = false, want true by default")
+		}
+	})
+}
+
+func TestNewConfigSessionGroupBy(t *testing.T) {
+	t.Setenv("ACCEL_EXPORTER_PORT", "")
+	withArgs(t, []string{"-collector.sessions-by", "-collector.sessions-by.group-by= type, ,inbound_if"}, func() {
+		cfg := NewConfig()
+		if !cfg.SessionsBy {
+			t.Error("SessionsBy = false, want true")
+		}
+		if want := []string{"type", "inbound_if"}; !slices.Equal(cfg.SessionGroupBy, want) {
+			t.Errorf("SessionGroupBy = %v, want %v", cfg.SessionGroupBy, want)
+		}
 	})
 }
 
@@ -48,6 +73,7 @@ func TestNewConfigFlags(t *testing.T) {
 		"-web.metrics-path=/m",
 		"-accel-cmd.path=/usr/sbin/accel-cmd",
 		"-log.level=debug",
+		"-collector.strict-parsing",
 	}
 	withArgs(t, args, func() {
 		cfg := NewConfig()
@@ -63,6 +89,9 @@ func TestNewConfigFlags(t *testing.T) {
 		if cfg.LogLevel != "debug" {
 			t.Errorf("LogLevel = %q, want debug", cfg.LogLevel)
 		}
+		if !cfg.StrictParsing {
+			t.Error("StrictParsing = false, want true")
+		}
 	})
 }
 
@@ -77,3 +106,21 @@ func TestNewConfigPortEnvOverride(t *testing.T) {
 		}
 	})
 }
+
+func TestNewConfigUptimeBuckets(t *testing.T) {
+	t.Setenv("ACCEL_EXPORTER_PORT", "")
+	withArgs(t, []string{"-collector.sessions-uptime.buckets=30, 60,1.5e3"}, func() {
+		if got, want := NewConfig().UptimeBuckets, []float64{30, 60, 1500}; !slices.Equal(got, want) {
+			t.Errorf("UptimeBuckets = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestFloatListSetRejectsBadInput(t *testing.T) {
+	for _, in := range []string{"1,x", "", " , "} {
+		var l floatList
+		if err := l.Set(in); err == nil {
+			t.Errorf("Set(%q) = nil, want error", in)
+		}
+	}
+}
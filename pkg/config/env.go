@@ -0,0 +1,51 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvPrefix starts the environment variable of every flag (see EnvName).
+const EnvPrefix = "ACCEL_EXPORTER_"
+
+// repeatableFlags may be given several times on the command line; their
+// environment variables hold the values separated by semicolons.
+var repeatableFlags = map[string]bool{
+	"instance":     true,
+	"probe.module": true,
+}
+
+// EnvName returns the environment variable setting flag name, e.g.
+// ACCEL_EXPORTER_WEB_LISTEN_ADDRESS for web.listen-address.
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+}
+
+// applyEnv sets every flag of fs not given on the command line from its
+// non-empty environment variable, so environment variables sit between the
+// command line and the config file in precedence.
+func applyEnv(fs *flag.FlagSet) error {
+	cli := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { cli[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value := os.Getenv(EnvName(f.Name))
+		if err != nil || cli[f.Name] || value == "" {
+			return
+		}
+		values := []string{value}
+		if repeatableFlags[f.Name] {
+			values = strings.Split(value, ";")
+		}
+		for _, v := range values {
+			if setErr := fs.Set(f.Name, strings.TrimSpace(v)); setErr != nil {
+				err = fmt.Errorf("%s: %w", EnvName(f.Name), setErr)
+				return
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"web.listen-address":             "ACCEL_EXPORTER_WEB_LISTEN_ADDRESS",
+		"accel-cmd.timeout":              "ACCEL_EXPORTER_ACCEL_CMD_TIMEOUT",
+		"collector.sessions-by.group-by": "ACCEL_EXPORTER_COLLECTOR_SESSIONS_BY_GROUP_BY",
+		"instance":                       "ACCEL_EXPORTER_INSTANCE",
+	}
+	for name, want := range tests {
+		if got := EnvName(name); got != want {
+			t.Errorf("EnvName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// TestNewConfigEnv checks the precedence of flags over the environment over
+// the config file.
+func TestNewConfigEnv(t *testing.T) {
+	path := writeFile(t, "web:\n  metrics_path: /file\naccel_cmd:\n  timeout: 9s\n")
+	t.Setenv("ACCEL_EXPORTER_PORT", "")
+	t.Setenv("ACCEL_EXPORTER_CONFIG_FILE", path)
+	t.Setenv("ACCEL_EXPORTER_WEB_LISTEN_ADDRESS", "127.0.0.1:9200")
+	t.Setenv("ACCEL_EXPORTER_ACCEL_CMD_TIMEOUT", "2s")
+	t.Setenv("ACCEL_EXPORTER_LOG_LEVEL", "debug")
+	t.Setenv("ACCEL_EXPORTER_COLLECTOR_SESSIONS_BY_GROUP_BY", "type")
+	t.Setenv("ACCEL_EXPORTER_INSTANCE", "name=a; name=b,transport=tcp")
+	withArgs(t, []string{"-log.level=warn"}, func() {
+		cfg := NewConfig()
+		if cfg.ListenAddress != "127.0.0.1:9200" {
+			t.Errorf("ListenAddress = %q, want 127.0.0.1:9200 from the environment", cfg.ListenAddress)
+		}
+		if cfg.ScrapeTimeout != 2*time.Second {
+			t.Errorf("ScrapeTimeout = %s, want the environment's 2s over the file's", cfg.ScrapeTimeout)
+		}
+		if cfg.MetricsPath != "/file" {
+			t.Errorf("MetricsPath = %q, want /file from the file", cfg.MetricsPath)
+		}
+		if cfg.LogLevel != "warn" {
+			t.Errorf("LogLevel = %q, want the flag's warn over the environment", cfg.LogLevel)
+		}
+		if len(cfg.SessionGroupBy) != 1 || cfg.SessionGroupBy[0] != "type" {
+			t.Errorf("SessionGroupBy = %v, want [type]", cfg.SessionGroupBy)
+		}
+		if len(cfg.Instances) != 2 || cfg.Instances[1].Transport != "tcp" {
+			t.Errorf("Instances = %+v, want a and b", cfg.Instances)
+		}
+	})
+}
+
+// TestNewConfigPortEnvKeepsHost checks the legacy ACCEL_EXPORTER_PORT only
+// replaces the port of the listen address.
+func TestNewConfigPortEnvKeepsHost(t *testing.T) {
+	t.Setenv("ACCEL_EXPORTER_PORT", "9300")
+	t.Setenv("ACCEL_EXPORTER_WEB_LISTEN_ADDRESS", "[::1]:9200")
+	withArgs(t, nil, func() {
+		if got := NewConfig().ListenAddress; got != "[::1]:9300" {
+			t.Errorf("ListenAddress = %q, want [::1]:9300", got)
+		}
+	})
+}
+
+func TestApplyEnvRejectsBadValues(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("collector.sessions-top", 0, "")
+	t.Setenv("ACCEL_EXPORTER_COLLECTOR_SESSIONS_TOP", "many")
+	if err := applyEnv(fs); err == nil || !strings.Contains(err.Error(), "ACCEL_EXPORTER_COLLECTOR_SESSIONS_TOP") {
+		t.Errorf("applyEnv = %v, want an error naming the variable", err)
+	}
+}
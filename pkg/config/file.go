@@ -0,0 +1,206 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// fileConfig is the layout of -config.file. Every option mirrors a flag;
+// pointers tell an option the file leaves out from one it sets to zero.
+type fileConfig struct {
+	Web struct {
+		ListenAddress   *string `yaml:"listen_address"`
+		MetricsPath     *string `yaml:"metrics_path"`
+		EnableProbe     *bool   `yaml:"enable_probe"`
+		EnableLifecycle *bool   `yaml:"enable_lifecycle"`
+	} `yaml:"web"`
+	AccelCmd struct {
+		Path    *string        `yaml:"path"`
+		Timeout *time.Duration `yaml:"timeout"`
+	} `yaml:"accel_cmd"`
+	AccelCLI struct {
+		Address  *string `yaml:"address"`
+		Password *string `yaml:"password"`
+	} `yaml:"accel_cli"`
+	AccelStat struct {
+		File *string `yaml:"file"`
+	} `yaml:"accel_stat"`
+	Collector struct {
+		UnknownStats          *bool          `yaml:"unknown_stats"`
+		StrictParsing         *bool          `yaml:"strict_parsing"`
+		Version               *bool          `yaml:"version"`
+		Sessions              *bool          `yaml:"sessions"`
+		SessionsLimit         *int           `yaml:"sessions_limit"`
+		SessionsBy            *bool          `yaml:"sessions_by"`
+		SessionsByGroupBy     []string       `yaml:"sessions_by_group_by"`
+		SessionsUptime        *bool          `yaml:"sessions_uptime"`
+		SessionsUptimeBuckets []float64      `yaml:"sessions_uptime_buckets"`
+		SessionsTop           *int           `yaml:"sessions_top"`
+		PollInterval          *time.Duration `yaml:"poll_interval"`
+	} `yaml:"collector"`
+	Log struct {
+		Level  *string `yaml:"level"`
+		Format *string `yaml:"format"`
+	} `yaml:"log"`
+	Instances    []fileInstance        `yaml:"instances"`
+	ProbeModules map[string]fileModule `yaml:"probe_modules"`
+}
+
+type fileInstance struct {
+	Name      string   `yaml:"name"`
+	Transport string   `yaml:"transport"`
+	Host      string   `yaml:"host"`
+	Port      int      `yaml:"port"`
+	Password  string   `yaml:"password"`
+	Args      []string `yaml:"args"`
+}
+
+type fileModule struct {
+	Password string        `yaml:"password"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Targets  string        `yaml:"targets"`
+}
+
+// applyFile reads the YAML config file at path and applies its options to c,
+// except those whose flag is in set (flags override the file). Unknown keys,
+// malformed values and paths that do not exist are errors.
+func (c *Config) applyFile(path string, set map[string]bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var f fileConfig
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := f.validate(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	apply(&c.ListenAddress, f.Web.ListenAddress, "web.listen-address", set)
+	apply(&c.MetricsPath, f.Web.MetricsPath, "web.metrics-path", set)
+	apply(&c.EnableProbe, f.Web.EnableProbe, "web.enable-probe", set)
+	apply(&c.EnableLifecycle, f.Web.EnableLifecycle, "web.enable-lifecycle", set)
+	apply(&c.AccelCmdPath, f.AccelCmd.Path, "accel-cmd.path", set)
+	apply(&c.ScrapeTimeout, f.AccelCmd.Timeout, "accel-cmd.timeout", set)
+	apply(&c.CLIAddress, f.AccelCLI.Address, "accel-cli.address", set)
+	apply(&c.CLIPassword, f.AccelCLI.Password, "accel-cli.password", set)
+	apply(&c.StatFile, f.AccelStat.File, "accel-stat.file", set)
+	apply(&c.UnknownStats, f.Collector.UnknownStats, "collector.unknown-stats", set)
+	apply(&c.StrictParsing, f.Collector.StrictParsing, "collector.strict-parsing", set)
+	apply(&c.VersionInfo, f.Collector.Version, "collector.version", set)
+	apply(&c.Sessions, f.Collector.Sessions, "collector.sessions", set)
+	apply(&c.SessionLimit, f.Collector.SessionsLimit, "collector.sessions.limit", set)
+	apply(&c.SessionsBy, f.Collector.SessionsBy, "collector.sessions-by", set)
+	applyList(&c.SessionGroupBy, f.Collector.SessionsByGroupBy, "collector.sessions-by.group-by", set)
+	apply(&c.SessionUptime, f.Collector.SessionsUptime, "collector.sessions-uptime", set)
+	applyList(&c.UptimeBuckets, f.Collector.SessionsUptimeBuckets, "collector.sessions-uptime.buckets", set)
+	apply(&c.TopSessions, f.Collector.SessionsTop, "collector.sessions-top", set)
+	apply(&c.PollInterval, f.Collector.PollInterval, "collector.poll-interval", set)
+	apply(&c.LogLevel, f.Log.Level, "log.level", set)
+	apply(&c.LogFormat, f.Log.Format, "log.format", set)
+
+	if len(f.Instances) > 0 && !set["instance"] {
+		c.Instances = nil
+		for _, fi := range f.Instances {
+			c.Instances = append(c.Instances, fi.instance())
+		}
+	}
+	// Modules merge by name, a -probe.module flag replacing the file's
+	// module of the same name.
+	if c.Modules == nil {
+		c.Modules = make(map[string]Module, len(f.ProbeModules))
+	}
+	for name, fm := range f.ProbeModules {
+		if _, ok := c.Modules[name]; !ok || !set["probe.module"] {
+			// The pattern compiled in validate.
+			targets, _ := compileTargets(fm.Targets)
+			c.Modules[name] = Module{Name: name, Password: fm.Password, Timeout: fm.Timeout, Targets: targets}
+		}
+	}
+	return nil
+}
+
+// apply sets *dst to the file value v, if present and flag was not set on
+// the command line.
+func apply[T any](dst *T, v *T, flag string, set map[string]bool) {
+	if v != nil && !set[flag] {
+		*dst = *v
+	}
+}
+
+// applyList is apply for list options, which are present when non-empty.
+func applyList[T any](dst *[]T, v []T, flag string, set map[string]bool) {
+	if len(v) > 0 && !set[flag] {
+		*dst = v
+	}
+}
+
+func (fi fileInstance) instance() Instance {
+	inst := Instance(fi)
+	if inst.Transport == "" {
+		inst.Transport = "exec"
+	}
+	return inst
+}
+
+// validate checks what the YAML types cannot express. Errors name the
+// offending option by its path in the file.
+func (f *fileConfig) validate() error {
+	if p := f.Web.MetricsPath; p != nil && !strings.HasPrefix(*p, "/") {
+		return fmt.Errorf("web.metrics_path %q must start with /", *p)
+	}
+	if p := f.AccelCmd.Path; p != nil {
+		if _, err := exec.LookPath(*p); err != nil {
+			return fmt.Errorf("accel_cmd.path: %w", err)
+		}
+	}
+	if p := f.AccelStat.File; p != nil && *p != "" {
+		if _, err := os.Stat(*p); err != nil {
+			return fmt.Errorf("accel_stat.file: %w", err)
+		}
+	}
+	if d := f.AccelCmd.Timeout; d != nil && *d <= 0 {
+		return fmt.Errorf("accel_cmd.timeout %s must be positive", *d)
+	}
+	if d := f.Collector.PollInterval; d != nil && *d < 0 {
+		return fmt.Errorf("collector.poll_interval %s must not be negative", *d)
+	}
+	if l := f.Log.Level; l != nil {
+		if err := new(slog.Level).UnmarshalText([]byte(*l)); err != nil {
+			return fmt.Errorf("log.level %q is not one of debug, info, warn, error", *l)
+		}
+	}
+	if l := f.Log.Format; l != nil && *l != "logfmt" && *l != "json" {
+		return fmt.Errorf("log.format %q is neither logfmt nor json", *l)
+	}
+	names := make(map[string]bool, len(f.Instances))
+	for i, fi := range f.Instances {
+		if err := fi.instance().validate(); err != nil {
+			return fmt.Errorf("instances[%d]: %w", i, err)
+		}
+		if names[fi.Name] {
+			return fmt.Errorf("instances[%d]: duplicate instance name %q", i, fi.Name)
+		}
+		names[fi.Name] = true
+	}
+	for name, fm := range f.ProbeModules {
+		if fm.Timeout < 0 {
+			return fmt.Errorf("probe_modules.%s.timeout %s must not be negative", name, fm.Timeout)
+		}
+		targets, err := compileTargets(fm.Targets)
+		if err != nil {
+			return fmt.Errorf("probe_modules.%s.targets: %w", name, err)
+		}
+		if err := (Module{Name: name, Password: fm.Password, Targets: targets}).validate(); err != nil {
+			return fmt.Errorf("probe_modules.%s: %w", name, err)
+		}
+	}
+	return nil
+}
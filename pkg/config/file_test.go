@@ -0,0 +1,170 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes content to a file in a test directory and returns its path.
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "accel-exporter.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewConfigFile(t *testing.T) {
+	t.Setenv("ACCEL_EXPORTER_PORT", "")
+	path := writeFile(t, `
+web:
+  listen_address: ":9200"
+accel_cmd:
+  timeout: 3s
+collector:
+  version: false
+  sessions_by_group_by: [type, state]
+  sessions_uptime_buckets: [60, 3600]
+  poll_interval: 15s
+instances:
+  - name: vrf1
+    transport: tcp
+    port: 2002
+probe_modules:
+  bras:
+    password: s3cret
+    timeout: 2s
+    targets: 'bras\d+:2001'
+  dc1:
+    targets: '10\.1\..*'
+`)
+	withArgs(t, []string{"-config.file=" + path, "-accel-cmd.timeout=7s"}, func() {
+		cfg := NewConfig()
+		if cfg.ListenAddress != ":9200" {
+			t.Errorf("ListenAddress = %q, want :9200 from the file", cfg.ListenAddress)
+		}
+		if cfg.ScrapeTimeout != 7*time.Second {
+			t.Errorf("ScrapeTimeout = %s, want the flag's 7s", cfg.ScrapeTimeout)
+		}
+		if cfg.MetricsPath != "/metrics" {
+			t.Errorf("MetricsPath = %q, want the default", cfg.MetricsPath)
+		}
+		if cfg.VersionInfo {
+			t.Error("VersionInfo = true, want false from the file")
+		}
+		if want := []string{"type", "state"}; !slices.Equal(cfg.SessionGroupBy, want) {
+			t.Errorf("SessionGroupBy = %v, want %v", cfg.SessionGroupBy, want)
+		}
+		if want := []float64{60, 3600}; !slices.Equal(cfg.UptimeBuckets, want) {
+			t.Errorf("UptimeBuckets = %v, want %v", cfg.UptimeBuckets, want)
+		}
+		if cfg.PollInterval != 15*time.Second {
+			t.Errorf("PollInterval = %s, want 15s", cfg.PollInterval)
+		}
+		if want := []Instance{{Name: "vrf1", Transport: "tcp", Port: 2002}}; !reflect.DeepEqual(cfg.Instances, want) {
+			t.Errorf("Instances = %+v, want %+v", cfg.Instances, want)
+		}
+		if bras := cfg.Modules["bras"]; bras.Password != "s3cret" || bras.Timeout != 2*time.Second || !bras.Allows("bras1:2001") {
+			t.Errorf("Modules[bras] = %+v, want password s3cret, timeout 2s and targets bras\\d+:2001", bras)
+		}
+		if dc1 := cfg.Modules["dc1"]; !dc1.Allows("10.1.2.3:2001") || dc1.Allows("10.2.2.3:2001") {
+			t.Errorf("Modules[dc1].Targets = %v, want 10\\.1\\..*", dc1.Targets)
+		}
+	})
+}
+
+func TestApplyFileFlagsOverride(t *testing.T) {
+	path := writeFile(t, `
+instances:
+  - name: from-file
+probe_modules:
+  bras: {password: file, targets: bras}
+  lab: {password: file, targets: lab}
+`)
+	c := &Config{
+		Instances: []Instance{{Name: "from-flag", Transport: "exec"}},
+		Modules:   map[string]Module{"bras": {Name: "bras", Password: "flag"}},
+	}
+	if err := c.applyFile(path, map[string]bool{"instance": true, "probe.module": true}); err != nil {
+		t.Fatalf("applyFile: %v", err)
+	}
+	if len(c.Instances) != 1 || c.Instances[0].Name != "from-flag" {
+		t.Errorf("Instances = %+v, want only the -instance flag's", c.Instances)
+	}
+	if c.Modules["bras"].Password != "flag" || c.Modules["lab"].Password != "file" {
+		t.Errorf("Modules = %+v, want bras from the flag and lab from the file", c.Modules)
+	}
+}
+
+func TestApplyFileErrors(t *testing.T) {
+	tests := map[string]string{
+		"web:\n  listen_adress: :9101\n":               "field listen_adress not found",
+		"accel_cmd:\n  timeout: soon\n":                "line 2: cannot unmarshal !!str `soon` into time.Duration",
+		"accel_cmd:\n  timeout: 0s\n":                  "accel_cmd.timeout 0s must be positive",
+		"accel_cmd:\n  path: /nonexistent/accel-cmd\n": "accel_cmd.path",
+		"accel_stat:\n  file: /nonexistent/stat\n":     "accel_stat.file",
+		"web:\n  metrics_path: metrics\n":              "must start with /",
+		"instances:\n  - transport: tcp\n":             "instances[0]: missing name",
+		"instances:\n  - {name: a, transport: ssh}\n":  "instances[0]: transport \"ssh\"",
+		"instances:\n  - name: a\n  - name: a\n":       "instances[1]: duplicate",
+		"probe_modules:\n  bras: {timeout: -1s}\n":     "probe_modules.bras.timeout",
+		"probe_modules:\n  bras: {targets: \"(\"}\n":   "probe_modules.bras.targets",
+		"probe_modules:\n  bras: {password: x}\n":      "probe_modules.bras: password without targets",
+		"log:\n  level: loud\n":                        "log.level \"loud\"",
+		"log:\n  format: xml\n":                        "log.format \"xml\"",
+	}
+	for content, want := range tests {
+		path := writeFile(t, content)
+		c := &Config{Modules: map[string]Module{}}
+		err := c.applyFile(path, nil)
+		if err == nil || !strings.Contains(err.Error(), want) || !strings.Contains(err.Error(), path) {
+			t.Errorf("applyFile(%q) = %v, want error naming the file and containing %q", content, err, want)
+		}
+	}
+
+	c := &Config{}
+	if err := c.applyFile(filepath.Join(t.TempDir(), "missing.yml"), nil); err == nil {
+		t.Error("applyFile of a missing file succeeded")
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	t.Setenv("ACCEL_EXPORTER_PORT", "")
+	path := writeFile(t, "accel_cmd:\n  timeout: 3s\ncollector:\n  sessions_top: 5\n")
+	withArgs(t, []string{"-config.file=" + path, "-collector.sessions-top=10"}, func() {
+		cfg := NewConfig()
+
+		if err := os.WriteFile(path, []byte("accel_cmd:\n  timeout: 4s\nprobe_modules:\n  bras: {}\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := cfg.Reload()
+		if err != nil {
+			t.Fatalf("Reload: %v", err)
+		}
+		if got.ScrapeTimeout != 4*time.Second {
+			t.Errorf("ScrapeTimeout = %s, want 4s from the edited file", got.ScrapeTimeout)
+		}
+		if got.TopSessions != 10 {
+			t.Errorf("TopSessions = %d, want the flag's 10", got.TopSessions)
+		}
+		if _, ok := got.Modules["bras"]; !ok {
+			t.Error("module added to the file is missing")
+		}
+		if cfg.ScrapeTimeout != 3*time.Second || len(cfg.Modules) != 0 {
+			t.Errorf("Reload modified the original config: %+v", cfg)
+		}
+
+		if err := os.WriteFile(path, []byte("accel_cmd:\n  timeout: 0\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := got.Reload(); err == nil {
+			t.Error("Reload of an invalid file succeeded")
+		}
+	})
+}
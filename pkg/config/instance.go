@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Instance is one named accel-pppd instance on the host, for setups running
+// several (e.g. one per VRF, each with its own config and CLI port).
+type Instance struct {
+	// Name is exported as the instance_name label.
+	Name string
+	// Transport is "exec" (run accel-cmd, the default) or "tcp" (query the
+	// TCP CLI directly).
+	Transport string
+	// Host and Port locate the instance's CLI. For exec they are passed to
+	// accel-cmd as -H and -p when set; for tcp they default to accel-ppp's
+	// 127.0.0.1:2001.
+	Host string
+	Port int
+	// Password is the CLI password (accel-cmd's -P).
+	Password string
+	// Args are extra accel-cmd arguments, e.g. "-4" or "-t 3".
+	Args []string
+}
+
+// Address returns the instance's CLI address for the tcp transport.
+func (i Instance) Address() string {
+	host, port := i.Host, i.Port
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if port == 0 {
+		port = 2001
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(port))
+}
+
+// AccelCmdArgs returns the accel-cmd arguments selecting the instance, to be
+// placed before the command.
+func (i Instance) AccelCmdArgs() []string {
+	var args []string
+	if i.Host != "" {
+		args = append(args, "-H", i.Host)
+	}
+	if i.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(i.Port))
+	}
+	if i.Password != "" {
+		args = append(args, "-P", i.Password)
+	}
+	return append(args, i.Args...)
+}
+
+// parseInstance parses an -instance value: comma-separated key=value pairs
+// with keys name (required), transport, host, port, password and args
+// (space-separated). Values cannot contain commas.
+func parseInstance(s string) (Instance, error) {
+	inst := Instance{Transport: "exec"}
+	for _, kv := range splitList(s) {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return inst, fmt.Errorf("%q is not key=value", kv)
+		}
+		switch key = strings.TrimSpace(key); key {
+		case "name":
+			inst.Name = value
+		case "transport":
+			inst.Transport = value
+		case "host":
+			inst.Host = value
+		case "port":
+			port, err := strconv.Atoi(value)
+			if err != nil || port < 1 {
+				return inst, fmt.Errorf("invalid port %q", value)
+			}
+			inst.Port = port
+		case "password":
+			inst.Password = value
+		case "args":
+			inst.Args = strings.Fields(value)
+		default:
+			return inst, fmt.Errorf("unknown key %q (valid: name, transport, host, port, password, args)", key)
+		}
+	}
+	return inst, inst.validate()
+}
+
+// validate checks the fields parseInstance and the config file cannot type
+// check.
+func (i Instance) validate() error {
+	if i.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if i.Transport != "exec" && i.Transport != "tcp" {
+		return fmt.Errorf("transport %q is neither exec nor tcp", i.Transport)
+	}
+	if i.Port < 0 || i.Port > 65535 {
+		return fmt.Errorf("invalid port %d", i.Port)
+	}
+	return nil
+}
+
+// instanceList is a flag.Value collecting repeated -instance flags.
+type instanceList []Instance
+
+func (l *instanceList) String() string {
+	names := make([]string, len(*l))
+	for i, inst := range *l {
+		names[i] = inst.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func (l *instanceList) Set(s string) error {
+	inst, err := parseInstance(s)
+	if err != nil {
+		return err
+	}
+	for _, other := range *l {
+		if other.Name == inst.Name {
+			return fmt.Errorf("duplicate instance name %q", inst.Name)
+		}
+	}
+	*l = append(*l, inst)
+	return nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewConfigInstances(t *testing.T) {
+	t.Setenv("ACCEL_EXPORTER_PORT", "")
+	args := []string{
+		"-instance=name=vrf1,host=127.0.0.1,port=2001,password=s3cret",
+		"-instance=name=vrf2,transport=tcp,port=2002",
+	}
+	withArgs(t, args, func() {
+		got := NewConfig().Instances
+		want := []Instance{
+			{Name: "vrf1", Transport: "exec", Host: "127.0.0.1", Port: 2001, Password: "s3cret"},
+			{Name: "vrf2", Transport: "tcp", Port: 2002},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Instances = %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestInstanceListSetRejectsBadInput(t *testing.T) {
+	tests := map[string]string{
+		"host=127.0.0.1":       "missing name",
+		"name=a,port=99999":    "invalid port",
+		"name=a,transport=ssh": "neither exec nor tcp",
+		"name=a,colour=blue":   "unknown key",
+		"name=a,host":          "not key=value",
+	}
+	for in, want := range tests {
+		var l instanceList
+		if err := l.Set(in); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Set(%q) = %v, want error containing %q", in, err, want)
+		}
+	}
+
+	var l instanceList
+	if err := l.Set("name=a"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := l.Set("name=a,port=2002"); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("Set of a duplicate name = %v, want duplicate error", err)
+	}
+}
+
+func TestInstanceAccelCmdArgs(t *testing.T) {
+	inst := Instance{Host: "10.0.0.1", Port: 2002, Password: "pw", Args: []string{"-t", "3"}}
+	want := []string{"-H", "10.0.0.1", "-p", "2002", "-P", "pw", "-t", "3"}
+	if got := inst.AccelCmdArgs(); !slices.Equal(got, want) {
+		t.Errorf("AccelCmdArgs = %q, want %q", got, want)
+	}
+	if got := (Instance{}).AccelCmdArgs(); len(got) != 0 {
+		t.Errorf("AccelCmdArgs of the default instance = %q, want none", got)
+	}
+}
+
+func TestInstanceAddress(t *testing.T) {
+	tests := []struct {
+		inst Instance
+		want string
+	}{
+		{Instance{}, "127.0.0.1:2001"},
+		{Instance{Port: 2002}, "127.0.0.1:2002"},
+		{Instance{Host: "2001:db8::1", Port: 2002}, "[2001:db8::1]:2002"},
+		{Instance{Host: "[2001:db8::1]"}, "[2001:db8::1]:2001"},
+	}
+	for _, tt := range tests {
+		if got := tt.inst.Address(); got != tt.want {
+			t.Errorf("%+v.Address() = %q, want %q", tt.inst, got, tt.want)
+		}
+	}
+}
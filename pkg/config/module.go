@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultModule is the /probe module used when a request names none. It has
+// no password and the -accel-cmd.timeout deadline unless configured with
+// -probe.module name=default,...
+const DefaultModule = "default"
+
+// Module holds the settings /probe applies to a target: the credentials and
+// timeout shared by a group of accel-ppp nodes.
+type Module struct {
+	Name string
+	// Password is the CLI password of the targets.
+	Password string
+	// Timeout bounds each query; zero means -accel-cmd.timeout.
+	Timeout time.Duration
+	// Targets, if not nil, is the host:port pattern targets must match, so
+	// that the password is only ever sent to the module's own nodes. It is
+	// required with a Password.
+	Targets *regexp.Regexp
+}
+
+// Allows reports whether the module may probe target.
+func (m Module) Allows(target string) bool {
+	return m.Targets == nil || m.Targets.MatchString(target)
+}
+
+// compileTargets compiles a module's targets pattern, anchored at both ends
+// like Prometheus relabelling regexps. An empty pattern allows any target.
+func compileTargets(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// parseModule parses a -probe.module value: comma-separated key=value pairs
+// with keys name (required), password, timeout and targets. Values cannot
+// contain commas.
+func parseModule(s string) (Module, error) {
+	var m Module
+	for _, kv := range splitList(s) {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return m, fmt.Errorf("%q is not key=value", kv)
+		}
+		switch key = strings.TrimSpace(key); key {
+		case "name":
+			m.Name = value
+		case "password":
+			m.Password = value
+		case "timeout":
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return m, fmt.Errorf("invalid timeout %q", value)
+			}
+			m.Timeout = d
+		case "targets":
+			re, err := compileTargets(value)
+			if err != nil {
+				return m, fmt.Errorf("invalid targets: %w", err)
+			}
+			m.Targets = re
+		default:
+			return m, fmt.Errorf("unknown key %q (valid: name, password, timeout, targets)", key)
+		}
+	}
+	if m.Name == "" {
+		return m, fmt.Errorf("missing name")
+	}
+	if err := m.validate(); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// validate rejects a module that would send its password to any host a
+// /probe request names.
+func (m Module) validate() error {
+	if m.Password != "" && m.Targets == nil {
+		return fmt.Errorf("password without targets would be sent to any host; set targets to the module's nodes")
+	}
+	return nil
+}
+
+// moduleMap is a flag.Value collecting repeated -probe.module flags.
+type moduleMap map[string]Module
+
+func (m moduleMap) String() string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (m moduleMap) Set(s string) error {
+	mod, err := parseModule(s)
+	if err != nil {
+		return err
+	}
+	if _, dup := m[mod.Name]; dup {
+		return fmt.Errorf("duplicate module name %q", mod.Name)
+	}
+	m[mod.Name] = mod
+	return nil
+}
+
+// ProbeEnabled reports whether /probe is served: only when asked to with
+// -web.enable-probe or when modules are configured, as it otherwise lets any
+// client point the exporter at any host.
+func (c *Config) ProbeEnabled() bool {
+	return c.EnableProbe || len(c.Modules) > 0
+}
+
+// Module returns the named /probe module, name "" meaning DefaultModule. The
+// default module exists even when not configured.
+func (c *Config) Module(name string) (Module, bool) {
+	if name == "" {
+		name = DefaultModule
+	}
+	m, ok := c.Modules[name]
+	if !ok && name == DefaultModule {
+		return Module{Name: DefaultModule}, true
+	}
+	return m, ok
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewConfigModules(t *testing.T) {
+	t.Setenv("ACCEL_EXPORTER_PORT", "")
+	args := []string{
+		"-probe.module=name=bras,password=s3cret,timeout=2s,targets=bras:2001",
+		"-probe.module=name=lab",
+		`-probe.module=name=dc1,targets=10\.1\.\d+\.\d+:2001`,
+	}
+	withArgs(t, args, func() {
+		cfg := NewConfig()
+		if !cfg.ProbeEnabled() {
+			t.Error("ProbeEnabled = false with modules configured")
+		}
+		if got := cfg.Modules["bras"]; got.Password != "s3cret" || got.Timeout != 2*time.Second || !got.Allows("bras:2001") {
+			t.Errorf("Modules[bras] = %+v, want password s3cret, timeout 2s and targets bras:2001", got)
+		}
+		if got, ok := cfg.Module("lab"); !ok || got != (Module{Name: "lab"}) {
+			t.Errorf("Module(lab) = %+v, %v", got, ok)
+		}
+		if got, ok := cfg.Module(""); !ok || got.Name != DefaultModule {
+			t.Errorf("Module(\"\") = %+v, %v, want the implicit default module", got, ok)
+		}
+		if _, ok := cfg.Module("nope"); ok {
+			t.Error("Module(nope) found an unconfigured module")
+		}
+		for target, want := range map[string]bool{
+			"10.1.2.3:2001":      true,
+			"10.1.2.3:2002":      false,
+			"10.1.2.3:2001.evil": false,
+			"evil:10.1.2.3:2001": false,
+		} {
+			if got := cfg.Modules["dc1"].Allows(target); got != want {
+				t.Errorf("Modules[dc1].Allows(%q) = %v, want %v", target, got, want)
+			}
+		}
+		if !cfg.Modules["lab"].Allows("192.0.2.1:2001") {
+			t.Error("a module without targets does not allow every target")
+		}
+	})
+}
+
+func TestProbeEnabled(t *testing.T) {
+	t.Setenv("ACCEL_EXPORTER_PORT", "")
+	withArgs(t, nil, func() {
+		if NewConfig().ProbeEnabled() {
+			t.Error("ProbeEnabled = true without modules or -web.enable-probe")
+		}
+	})
+	withArgs(t, []string{"-web.enable-probe"}, func() {
+		if !NewConfig().ProbeEnabled() {
+			t.Error("ProbeEnabled = false with -web.enable-probe")
+		}
+	})
+}
+
+func TestModuleMapSetRejectsBadInput(t *testing.T) {
+	tests := map[string]string{
+		"password=x":          "missing name",
+		"name=a,timeout=soon": "invalid timeout",
+		"name=a,timeout=-1s":  "invalid timeout",
+		"name=a,host=b":       "unknown key",
+		"name=a,password":     "not key=value",
+		"name=a,targets=(":    "invalid targets",
+		"name=a,password=x":   "password without targets",
+	}
+	for in, want := range tests {
+		m := moduleMap{}
+		if err := m.Set(in); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Set(%q) = %v, want error containing %q", in, err, want)
+		}
+	}
+
+	m := moduleMap{}
+	if err := m.Set("name=a"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := m.Set("name=a,timeout=1s"); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("Set of a duplicate name = %v, want duplicate error", err)
+	}
+}
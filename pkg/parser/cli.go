@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultCLIAddress is where accel-ppp's `[cli] tcp=` listener binds by default.
+const DefaultCLIAddress = "127.0.0.1:2001"
+
+// maxCLIResponse bounds how much output a single CLI command may return, so a
+// misbehaving peer cannot make the exporter buffer without limit. It is sized
+// for `show sessions` on a node with tens of thousands of subscribers.
+const maxCLIResponse = 64 << 20
+
+// errAuthFailed is returned when accel-ppp rejects the configured password.
+var errAuthFailed = errors.New("accel-ppp cli: authentication failed")
+
+// errEmptyResponse is wrapped in a ParseError when the peer closes the
+// connection without writing anything, which is also how a CLI may react to
+// a wrong or missing password.
+var errEmptyResponse = errors.New("accel-ppp cli: connection closed without a response (wrong or missing password?)")
+
+// CLIClient speaks accel-ppp's `cli tcp=` protocol directly, so the exporter
+// does not need the accel-cmd binary or exec rights on the host.
+//
+// The protocol is line based: when the listener has a password configured the
+// first line sent must be that password, every following line is a command,
+// and the server answers each command with plain text. accel-ppp closes the
+// connection after the `exit` command, so sending it last makes EOF the
+// terminator of the response.
+type CLIClient struct {
+	// Address is the host:port of the accel-ppp CLI listener.
+	Address string
+	// Password is sent before the command when non-empty.
+	Password string
+}
+
+// Run sends the command made of args (e.g. "show", "stat") to accel-ppp and
+// returns its raw output. The context bounds dialling as well as the whole
+// exchange.
+func (c *CLIClient) Run(ctx context.Context, args ...string) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+	// Unblock the read if the context is cancelled without a deadline.
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	var req strings.Builder
+	if c.Password != "" {
+		req.WriteString(c.Password + "\n")
+	}
+	req.WriteString(strings.Join(args, " ") + "\nexit\n")
+	if _, err := io.WriteString(conn, req.String()); err != nil {
+		return nil, err
+	}
+
+	out, err := io.ReadAll(io.LimitReader(conn, maxCLIResponse+1))
+	if err != nil {
+		// The connection deadline is the context's, and may fire a moment
+		// before the context itself reports it.
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) && ctx.Err() == nil {
+			return nil, fmt.Errorf("%w: %w", ErrTimeout, context.DeadlineExceeded)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	if len(out) > maxCLIResponse {
+		return nil, fmt.Errorf("accel-ppp cli: response exceeds %d bytes", maxCLIResponse)
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, &ParseError{Err: errEmptyResponse}
+	}
+	if c.Password != "" && bytes.Contains(bytes.ToLower(firstLine(out)), []byte("authentication failed")) {
+		return nil, errAuthFailed
+	}
+	return out, nil
+}
+
+// firstLine returns b up to (not including) the first newline.
+func firstLine(b []byte) []byte {
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return b[:i]
+	}
+	return b
+}
@@ -0,0 +1,189 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCLI starts a local TCP stand-in for accel-ppp's `cli tcp=` listener. It
+// replays reply for "show stat", requires password as the first line when
+// non-empty, and closes the connection on "exit" like accel-ppp does. It
+// returns the listener address.
+func fakeCLI(t *testing.T, password, reply string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveCLI(conn, password, reply)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveCLI(conn net.Conn, password, reply string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := password == ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case !authed:
+			if line != password {
+				_, _ = conn.Write([]byte("authentication failed\r\n"))
+				return
+			}
+			authed = true
+		case line == "exit":
+			return
+		case line == "show stat":
+			_, _ = conn.Write([]byte(strings.ReplaceAll(reply, "\n", "\r\n")))
+		default:
+			_, _ = conn.Write([]byte("invalid command\r\n"))
+		}
+	}
+}
+
+// showStat runs show stat through client within timeout and parses the
+// reply, as the collector's TCPSource does.
+func showStat(client *CLIClient, timeout time.Duration) (*Stats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	out, err := client.Run(ctx, "show", "stat")
+	if err != nil {
+		return nil, err
+	}
+	return ParseStats(string(out))
+}
+
+func TestCLIClientShowStat(t *testing.T) {
+	addr := fakeCLI(t, "", sampleStat)
+	st, err := showStat(&CLIClient{Address: addr}, time.Second)
+	if err != nil {
+		t.Fatalf("showStat: %v", err)
+	}
+	wantEq(t, "CPUPercent", st.CPUPercent, 1.50)
+	wantEq(t, "PPPoE.RecvPADR", st.PPPoE.RecvPADR, 990)
+	if st.RadiusServers["1"].IP != "10.0.0.1" {
+		t.Errorf("radius IP = %q, want 10.0.0.1", st.RadiusServers["1"].IP)
+	}
+}
+
+func TestCLIClientShowStatPassword(t *testing.T) {
+	addr := fakeCLI(t, "s3cret", sampleStat)
+	st, err := showStat(&CLIClient{Address: addr, Password: "s3cret"}, time.Second)
+	if err != nil {
+		t.Fatalf("showStat: %v", err)
+	}
+	wantEq(t, "Sessions.Active", st.Sessions.Active, 100)
+}
+
+func TestCLIClientShowStatAuthFailed(t *testing.T) {
+	addr := fakeCLI(t, "s3cret", sampleStat)
+	_, err := showStat(&CLIClient{Address: addr, Password: "wrong"}, time.Second)
+	if got := FailureReason(err); got != ReasonAuth {
+		t.Fatalf("showStat error = %v (reason %q), want authentication error", err, got)
+	}
+}
+
+// TestCLIClientShowStatNotShowStat checks a peer that does not answer with show
+// stat — one that hangs up silently, as a CLI dropping a wrong password may,
+// or one that rejects the command — fails the scrape instead of passing for
+// a node reporting all zeros.
+func TestCLIClientShowStatNotShowStat(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// Read the request, then close without writing.
+			go func() {
+				_, _ = bufio.NewReader(conn).ReadString('\n')
+				conn.Close()
+			}()
+		}
+	}()
+	_, err = showStat(&CLIClient{Address: ln.Addr().String(), Password: "wrong"}, time.Second)
+	if !errors.Is(err, errEmptyResponse) || FailureReason(err) != ReasonParse {
+		t.Errorf("silent disconnect: error = %v, want a parse error for the empty response", err)
+	}
+
+	// A CLI that does not know the command answers "invalid command".
+	addr := fakeCLI(t, "", sampleStat)
+	out, err := (&CLIClient{Address: addr}).Run(context.Background(), "show", "nonsense")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if _, err := ParseStats(string(out)); FailureReason(err) != ReasonParse {
+		t.Errorf("invalid command reply %q: ParseStats error = %v, want a parse error", out, err)
+	}
+}
+
+func TestCLIClientShowStatConnRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	_, err = showStat(&CLIClient{Address: addr}, time.Second)
+	if got := FailureReason(err); got != ReasonConnection {
+		t.Fatalf("showStat error = %v (reason %q), want connection error", err, got)
+	}
+}
+
+// TestCLIClientShowStatTimeout proves a CLI that accepts but never answers is
+// abandoned at the deadline instead of wedging the scrape.
+func TestCLIClientShowStatTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// Swallow input and never reply; the client hangs up at its deadline.
+			go func() {
+				_, _ = io.Copy(io.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	start := time.Now()
+	_, err = showStat(&CLIClient{Address: ln.Addr().String()}, 50*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("showStat error = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("showStat blocked %v, timeout not enforced", elapsed)
+	}
+}
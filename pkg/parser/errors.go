@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Failure reasons reported by FailureReason. They are stable identifiers
+// suitable for metric labels.
+const (
+	ReasonNotFound   = "not_found"
+	ReasonPermission = "permission_denied"
+	ReasonTimeout    = "timeout"
+	ReasonExitStatus = "exit_status"
+	ReasonConnection = "connection"
+	ReasonAuth       = "auth"
+	ReasonParse      = "parse"
+	ReasonOther      = "other"
+)
+
+// FailureReasons lists every reason FailureReason can return.
+var FailureReasons = []string{
+	ReasonNotFound, ReasonPermission, ReasonTimeout, ReasonExitStatus,
+	ReasonConnection, ReasonAuth, ReasonParse, ReasonOther,
+}
+
+var (
+	// ErrNotFound is returned when the accel-cmd binary does not exist.
+	ErrNotFound = errors.New("accel-cmd not found")
+	// ErrPermission is returned when the accel-cmd binary cannot be executed.
+	ErrPermission = errors.New("accel-cmd not executable")
+	// ErrTimeout is returned when accel-ppp does not answer before the
+	// deadline.
+	ErrTimeout = errors.New("accel-ppp did not answer in time")
+)
+
+// ExitError is returned when accel-cmd exits with a non-zero status, which it
+// does e.g. when it cannot reach accel-ppp's CLI.
+type ExitError struct {
+	Code int
+	// Stderr is what accel-cmd printed to stderr, usually the reason,
+	// truncated to maxStderr bytes.
+	Stderr string
+}
+
+// Error includes stderr, folded onto one line so the message stays a single
+// log entry.
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("accel-cmd exited with status %d", e.Code)
+	if stderr := strings.Join(strings.Fields(e.Stderr), " "); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+// ParseError is returned when the output of accel-ppp cannot be parsed.
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string { return "parse accel-ppp output: " + e.Err.Error() }
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// FailureReason classifies an error returned by CollectStats, CLIClient.Run,
+// the parsers or the functions they build on into one of the Reason
+// constants, so timeouts can be told apart from misconfiguration.
+func FailureReason(err error) string {
+	var exitErr *ExitError
+	var parseErr *ParseError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, os.ErrDeadlineExceeded):
+		return ReasonTimeout
+	case errors.Is(err, ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return ReasonNotFound
+	case errors.Is(err, ErrPermission), errors.Is(err, fs.ErrPermission):
+		return ReasonPermission
+	case errors.As(err, &exitErr):
+		return ReasonExitStatus
+	case errors.Is(err, errAuthFailed):
+		return ReasonAuth
+	case errors.As(err, &parseErr):
+		return ReasonParse
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return ReasonTimeout
+		}
+		return ReasonConnection
+	}
+	return ReasonOther
+}
+
+// maxStderr bounds how much of accel-cmd's stderr is kept for ExitError.
+const maxStderr = 4 << 10
+
+// cappedBuffer keeps the first max bytes written to it and discards the rest,
+// so a chatty or looping child cannot make the exporter buffer without limit.
+type cappedBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// execError converts an error from running accel-cmd into one of the typed
+// errors above. ctx is the context the command ran under, which tells a kill
+// at the deadline apart from a crash.
+func execError(ctx context.Context, err error, stderr []byte) error {
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrTimeout, ctx.Err())
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("%w: %w", ErrPermission, err)
+	case errors.As(err, &exitErr):
+		return &ExitError{Code: exitErr.ExitCode(), Stderr: string(stderr)}
+	}
+	return err
+}
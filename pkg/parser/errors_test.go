@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectStatsErrors(t *testing.T) {
+	notExecutable := filepath.Join(t.TempDir(), "accel-cmd")
+	if err := os.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		timeout time.Duration
+		target  error
+		reason  string
+	}{
+		{"not found", "/nonexistent/accel-cmd-xyz", time.Second, ErrNotFound, ReasonNotFound},
+		{"not in PATH", "accel-cmd-xyz-not-in-path", time.Second, ErrNotFound, ReasonNotFound},
+		{"permission", notExecutable, time.Second, ErrPermission, ReasonPermission},
+		{"timeout", fakeAccelCmd(t, "sleep 10"), 50 * time.Millisecond, ErrTimeout, ReasonTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CollectStats(tt.path, tt.timeout)
+			if !errors.Is(err, tt.target) {
+				t.Errorf("CollectStats error = %v, want %v", err, tt.target)
+			}
+			if got := FailureReason(err); got != tt.reason {
+				t.Errorf("FailureReason = %q, want %q", got, tt.reason)
+			}
+		})
+	}
+}
+
+func TestCollectStatsExitError(t *testing.T) {
+	path := fakeAccelCmd(t, "echo 'connect: Connection refused' >&2; exit 3")
+	_, err := CollectStats(path, time.Second)
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("CollectStats error = %v, want *ExitError", err)
+	}
+	if exitErr.Code != 3 || strings.TrimSpace(exitErr.Stderr) != "connect: Connection refused" {
+		t.Errorf("ExitError = %+v, want code 3 with the stderr line", exitErr)
+	}
+	if want := "accel-cmd exited with status 3: connect: Connection refused"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if got := FailureReason(err); got != ReasonExitStatus {
+		t.Errorf("FailureReason = %q, want %q", got, ReasonExitStatus)
+	}
+}
+
+// TestCollectStatsStderrBounded guards that a child flooding stderr cannot
+// grow the kept output past maxStderr.
+func TestCollectStatsStderrBounded(t *testing.T) {
+	path := fakeAccelCmd(t, "head -c 100000 /dev/zero >&2; exit 1")
+	_, err := CollectStats(path, 5*time.Second)
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("CollectStats error = %v, want *ExitError", err)
+	}
+	if len(exitErr.Stderr) != maxStderr {
+		t.Errorf("len(Stderr) = %d, want %d", len(exitErr.Stderr), maxStderr)
+	}
+}
+
+func TestParseStatsError(t *testing.T) {
+	// A line longer than bufio.Scanner's limit cannot be tokenised.
+	_, err := ParseStats("cpu: " + strings.Repeat("9", 1<<17) + "\n")
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("ParseStats error = %v, want *ParseError", err)
+	}
+	if got := FailureReason(err); got != ReasonParse {
+		t.Errorf("FailureReason = %q, want %q", got, ReasonParse)
+	}
+}
+
+func TestFailureReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), ReasonTimeout},
+		{os.ErrDeadlineExceeded, ReasonTimeout},
+		{errAuthFailed, ReasonAuth},
+		{&os.PathError{Op: "open", Path: "stat.txt", Err: os.ErrNotExist}, ReasonNotFound},
+		{errors.New("boom"), ReasonOther},
+	}
+	for _, tt := range tests {
+		if got := FailureReason(tt.err); got != tt.want {
+			t.Errorf("FailureReason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
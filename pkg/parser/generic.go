@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// StatValue is one numeric value from a `show stat` line the typed parsers do
+// not know about. A tuple line such as "auth lost(total/5m/1m): 10 / 1 / 0"
+// yields one StatValue per element, named after the key hint in parentheses.
+type StatValue struct {
+	Section string
+	Key     string
+	Field   string
+	Value   float64
+}
+
+// keyHintRe splits a key like "auth lost(total/5m/1m)" into its base name and
+// the "/"-separated field names in parentheses.
+var keyHintRe = regexp.MustCompile(`^(.*?)\s*\(([^()]*/[^()]*)\)$`)
+
+// sectionName joins a section and optional nested block into the single
+// section label used for unknown values, e.g. "l2tp/sessions (data channels)".
+func sectionName(section, subsection string) string {
+	if subsection == "" {
+		return section
+	}
+	return section + "/" + subsection
+}
+
+// genericValues extracts numeric values from an unrecognised line. Scalars
+// yield one value with an empty Field; "a / b / c" tuples yield one value per
+// element, with Field taken from the key hint when the counts agree and the
+// element's index otherwise. Non-numeric lines (e.g. states) yield nothing, as
+// do tuples with any non-numeric element, so partial data is never exported.
+func genericValues(section, key, value string) []StatValue {
+	// Trailing units carry no information for a generic gauge.
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(value, "%"), " K"))
+	if value == "" {
+		return nil
+	}
+
+	parts := strings.Split(value, "/")
+	nums := make([]float64, len(parts))
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil
+		}
+		nums[i] = f
+	}
+
+	if len(nums) == 1 {
+		return []StatValue{{Section: section, Key: key, Value: nums[0]}}
+	}
+
+	var names []string
+	if m := keyHintRe.FindStringSubmatch(key); m != nil {
+		if hints := strings.Split(m[2], "/"); len(hints) == len(nums) {
+			key, names = m[1], hints
+		}
+	}
+	out := make([]StatValue, len(nums))
+	for i, f := range nums {
+		field := strconv.Itoa(i)
+		if names != nil {
+			field = strings.TrimSpace(names[i])
+		}
+		out[i] = StatValue{Section: section, Key: key, Field: field, Value: f}
+	}
+	return out
+}
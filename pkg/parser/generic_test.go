@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenericValues(t *testing.T) {
+	tests := []struct {
+		name       string
+		key, value string
+		want       []StatValue
+	}{
+		{"scalar", "queued", "7", []StatValue{{Section: "s", Key: "queued", Value: 7}}},
+		{"percent", "load", "1.5%", []StatValue{{Section: "s", Key: "load", Value: 1.5}}},
+		{"hinted tuple", "lost(total/5m/1m)", "10 / 1 / 0", []StatValue{
+			{Section: "s", Key: "lost", Field: "total", Value: 10},
+			{Section: "s", Key: "lost", Field: "5m", Value: 1},
+			{Section: "s", Key: "lost", Field: "1m", Value: 0},
+		}},
+		{"hint count mismatch", "lost(total/5m)", "10 / 1 / 0", []StatValue{
+			{Section: "s", Key: "lost(total/5m)", Field: "0", Value: 10},
+			{Section: "s", Key: "lost(total/5m)", Field: "1", Value: 1},
+			{Section: "s", Key: "lost(total/5m)", Field: "2", Value: 0},
+		}},
+		// A single-word parenthesis is part of the name, not a hint.
+		{"parenthesised scalar", "recv PADR(dup)", "2", []StatValue{{Section: "s", Key: "recv PADR(dup)", Value: 2}}},
+		{"kilobytes", "mem(rss/virt)", "12 / 34 K", []StatValue{
+			{Section: "s", Key: "mem", Field: "rss", Value: 12},
+			{Section: "s", Key: "mem", Field: "virt", Value: 34},
+		}},
+		{"non-numeric", "state", "active", nil},
+		{"partly numeric tuple", "lost(a/b)", "1 / x", nil},
+		{"empty", "x", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := genericValues("s", tt.key, tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("genericValues(%q, %q) = %+v, want %+v", tt.key, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseStatsUnknown verifies unknown sections, unknown keys in known
+// sections and unknown nested blocks are kept, while recognised lines are not
+// duplicated into Unknown.
+func TestParseStatsUnknown(t *testing.T) {
+	in := `uptime: 0.00:01:00
+pppoe:
+  active: 3
+  recv PADT: 12
+l2tp:
+  sessions (data channels):
+    active: 4
+newproto:
+  active: 9
+  lost(total/1m): 5 / 1
+`
+	st, err := ParseStats(in)
+	if err != nil {
+		t.Fatalf("ParseStats: %v", err)
+	}
+	want := []StatValue{
+		{Section: "pppoe", Key: "recv PADT", Value: 12},
+		{Section: "l2tp/sessions (data channels)", Key: "active", Value: 4},
+		{Section: "newproto", Key: "active", Value: 9},
+		{Section: "newproto", Key: "lost", Field: "total", Value: 5},
+		{Section: "newproto", Key: "lost", Field: "1m", Value: 1},
+	}
+	if !reflect.DeepEqual(st.Unknown, want) {
+		t.Errorf("Unknown = %+v, want %+v", st.Unknown, want)
+	}
+	wantEq(t, "PPPoE.Active", st.PPPoE.Active, 3)
+}
+
+// TestParseStatsSampleFullyKnown guards that every line of the reference
+// capture maps to a typed field, except l2tp data channels which have none.
+func TestParseStatsSampleFullyKnown(t *testing.T) {
+	st, err := ParseStats(sampleStat)
+	if err != nil {
+		t.Fatalf("ParseStats: %v", err)
+	}
+	for _, v := range st.Unknown {
+		if v.Section != "l2tp/sessions (data channels)" {
+			t.Errorf("unexpected unknown value %+v", v)
+		}
+	}
+}
@@ -1,12 +1,14 @@
-// Package parser executes accel-cmd and parses its `show stat` output into
-// typed statistics.
+// Package parser executes accel-cmd (or talks to accel-ppp's TCP CLI directly)
+// and parses its `show stat` output into typed statistics.
 package parser
 
 import (
 	"bufio"
 	"bytes"
 	"context"
-	"log"
+	"errors"
+	"net"
+	"net/netip"
 	"os/exec"
 	"regexp"
 	"strconv"
@@ -23,7 +25,29 @@ type Stats struct {
 	Core          CoreStats
 	Sessions      SessionStats
 	PPPoE         PPPoEStats
+	IPoE          IPoEStats
+	L2TP          L2TPStats
+	PPTP          PPTPStats
+	SSTP          SSTPStats
 	RadiusServers map[string]RadiusStats
+	// Unknown holds the numeric values of lines no section parser recognised,
+	// so counters added by newer accel-ppp releases can still be exported.
+	Unknown []StatValue
+	// Warnings lists the values of recognised lines that could not be
+	// parsed and were recorded as zero.
+	Warnings []Warning
+	// Timing records how long producing this snapshot took, when the caller
+	// measured it; ParseStats leaves it zero.
+	Timing Timing
+}
+
+// Timing breaks the cost of a snapshot down by phase.
+type Timing struct {
+	// Exec is the time spent querying accel-ppp (running accel-cmd or the
+	// TCP CLI round trip).
+	Exec time.Duration
+	// Parse is the time spent parsing the output.
+	Parse time.Duration
 }
 
 // CoreStats contains core metrics
@@ -62,9 +86,44 @@ type PPPoEStats struct {
 	Filtered    float64
 }
 
+// IPoEStats contains IPoE protocol metrics
+type IPoEStats struct {
+	Starting      float64
+	Active        float64
+	DelayedOffers float64
+}
+
+// L2TPStats contains L2TP tunnel and session metrics
+type L2TPStats struct {
+	Tunnels  L2TPCounters
+	Sessions L2TPCounters
+}
+
+// L2TPCounters holds the per-state counts accel-ppp reports for L2TP tunnels
+// and sessions
+type L2TPCounters struct {
+	Starting  float64
+	Active    float64
+	Finishing float64
+}
+
+// PPTPStats contains PPTP protocol metrics
+type PPTPStats struct {
+	Starting float64
+	Active   float64
+}
+
+// SSTPStats contains SSTP protocol metrics
+type SSTPStats struct {
+	Starting float64
+	Active   float64
+}
+
 // RadiusStats contains RADIUS server metrics
 type RadiusStats struct {
-	ID               string
+	ID string
+	// IP is the server address in canonical form: an IP literal without
+	// brackets (IPv6 compressed per RFC 5952) or a lower-cased hostname.
 	IP               string
 	State            string
 	FailCount        float64
@@ -90,6 +149,15 @@ type RadiusStats struct {
 	InterimAvgTime1m float64
 }
 
+// radiusHeaderRe matches a RADIUS section header such as "radius(1, 10.0.0.1)".
+// The address part is taken verbatim up to the closing parenthesis so IPv6
+// literals, bracketed addresses, ports and hostnames all match; it is
+// normalised by parseRadiusAddr.
+var radiusHeaderRe = regexp.MustCompile(`^radius\((\d+),\s*([^()\s]+)\)$`)
+
+// errNotShowStat is wrapped in a ParseError when output has no uptime line.
+var errNotShowStat = errors.New("no uptime line, not show stat output")
+
 // CollectStats executes accel-cmd and parses its output. The command is bounded
 // by timeout so a hung accel-cmd cannot wedge the scrape or leak processes; a
 // non-positive timeout disables the deadline.
@@ -101,40 +169,68 @@ func CollectStats(accelCmdPath string, timeout time.Duration) (*Stats, error) {
 		defer cancel()
 	}
 
-	cmd := exec.CommandContext(ctx, accelCmdPath, "show", "stat")
+	out, err := RunAccelCmd(ctx, accelCmdPath, "show", "stat")
+	if err != nil {
+		return nil, err
+	}
+	return ParseStats(string(out))
+}
+
+// RunAccelCmd executes accel-cmd with args and returns its stdout. The process
+// is killed when ctx is done. Failures are reported as ErrNotFound,
+// ErrPermission, ErrTimeout or an *ExitError carrying accel-cmd's stderr.
+func RunAccelCmd(ctx context.Context, accelCmdPath string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, accelCmdPath, args...)
 	// WaitDelay bounds how long Run blocks after the context is cancelled and the
 	// process killed. Without it, a child that forks (e.g. a shell wrapper that
 	// spawns a long-running grandchild) can inherit the stdout pipe and keep it
 	// open, leaving Run stuck reading until that grandchild exits.
 	cmd.WaitDelay = 2 * time.Second
 	var out bytes.Buffer
+	stderr := &cappedBuffer{max: maxStderr}
 	cmd.Stdout = &out
+	cmd.Stderr = stderr
 	if err := cmd.Run(); err != nil {
-		return nil, err
+		return nil, execError(ctx, err, stderr.buf.Bytes())
 	}
+	return out.Bytes(), nil
+}
 
-	return parseStats(out.String())
+// ParseStats parses the output of accel-cmd show stat with DefaultProfile.
+func ParseStats(output string) (*Stats, error) {
+	return ParseStatsProfile(output, DefaultProfile)
 }
 
-// parseStats parses the output of accel-cmd show stat
-func parseStats(output string) (*Stats, error) {
+// ParseStatsProfile parses the output of accel-cmd show stat as laid out by
+// the accel-ppp releases p covers (see ProfileFor).
+func ParseStatsProfile(output string, p *Profile) (*Stats, error) {
 	stats := &Stats{
 		RadiusServers: make(map[string]RadiusStats),
 	}
 
 	scanner := bufio.NewScanner(strings.NewReader(output))
-	var section string
+	var section, subsection string
+	var sawUptime bool
+	w := &warner{}
 
 	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.TrimSpace(line)
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
 
 		if line == "" {
 			continue
 		}
 
 		if strings.HasSuffix(line, ":") {
-			section = strings.TrimSuffix(line, ":")
+			name := strings.TrimSuffix(line, ":")
+			// An indented header opens a nested block inside the current
+			// section (e.g. l2tp's "tunnels:") rather than a new section, so
+			// it cannot be mistaken for a top-level block of the same name.
+			if section != "" && raw != strings.TrimLeft(raw, " \t") {
+				subsection = strings.TrimPrefix(p.header(section+"/"+name), section+"/")
+			} else {
+				section, subsection = p.header(name), ""
+			}
 			continue
 		}
 
@@ -146,244 +242,352 @@ func parseStats(output string) (*Stats, error) {
 		key := strings.TrimSpace(parts[0])
 		value := strings.TrimSpace(parts[1])
 
+		w.line(sectionName(section, subsection), key, value)
+		var known bool
 		switch section {
 		case "":
-			parseMainSection(stats, key, value)
+			sawUptime = sawUptime || key == "uptime"
+			known = parseMainSection(w, stats, key, value)
 		case "core":
-			parseCoreSection(&stats.Core, key, value)
+			known = parseCoreSection(w, &stats.Core, key, value)
 		case "sessions":
-			parseSessionsSection(&stats.Sessions, key, value)
+			known = parseSessionsSection(w, &stats.Sessions, key, value)
 		case "pppoe":
-			parsePPPoESection(&stats.PPPoE, key, value)
+			known = parsePPPoESection(w, &stats.PPPoE, key, value)
+		case "ipoe":
+			known = parseIPoESection(w, &stats.IPoE, key, value)
+		case "l2tp":
+			known = parseL2TPSection(w, &stats.L2TP, subsection, key, value)
+		case "pptp":
+			known = parsePPTPSection(w, &stats.PPTP, key, value)
+		case "sstp":
+			known = parseSSTPSection(w, &stats.SSTP, key, value)
 		default:
 			if strings.HasPrefix(section, "radius") {
-				radiusMatch := regexp.MustCompile(`radius\((\d+), ([\d\.]+)\)`).FindStringSubmatch(section)
+				radiusMatch := radiusHeaderRe.FindStringSubmatch(section)
 				if len(radiusMatch) == 3 {
 					radiusID := radiusMatch[1]
-					radiusIP := radiusMatch[2]
 					if _, exists := stats.RadiusServers[radiusID]; !exists {
 						stats.RadiusServers[radiusID] = RadiusStats{
 							ID: radiusID,
-							IP: radiusIP,
+							IP: parseRadiusAddr(radiusMatch[2]),
 						}
 					}
 
 					rs := stats.RadiusServers[radiusID]
-					parseRadiusSection(&rs, key, value)
+					known = parseRadiusSection(w, &rs, key, value)
 					stats.RadiusServers[radiusID] = rs
 				}
 			}
 		}
+		if !known {
+			stats.Unknown = append(stats.Unknown, genericValues(sectionName(section, subsection), key, value)...)
+		}
 	}
 
-	return stats, scanner.Err()
-}
-
-// atof parses a numeric field. An empty value yields 0 silently (accel-cmd
-// legitimately omits fields); a non-empty value that fails to parse yields 0
-// but is logged, so malformed output is visible to operators instead of
-// masquerading as a real zero.
-func atof(value string) float64 {
-	value = strings.TrimSpace(value)
-	if value == "" {
-		return 0
+	if err := scanner.Err(); err != nil {
+		return nil, &ParseError{Err: err}
 	}
-	f, err := strconv.ParseFloat(value, 64)
-	if err != nil {
-		log.Printf("parser: cannot parse %q as number: %v", value, err)
-		return 0
+	// Every show stat starts with uptime. Without it the output is
+	// something else — an "invalid command" reply, another service, or
+	// nothing at all — and must not pass for a node reporting all zeros.
+	if !sawUptime {
+		return nil, &ParseError{Err: errNotShowStat}
 	}
-	return f
+	stats.Warnings = w.warnings
+	return stats, nil
 }
 
-// fields splits a "/"-delimited value (e.g. "10 / 1 / 0") into want floats.
-// It returns ok=false when the field count differs, so callers leave the
-// destination untouched rather than recording partial data. An empty value is
-// a silent miss (accel-cmd may omit a field); a non-empty value with the wrong
-// count is logged so malformed output is visible to operators.
-func fields(value string, want int) ([]float64, bool) {
-	if strings.TrimSpace(value) == "" {
-		return nil, false
+// parseRadiusAddr drops any port from a RADIUS header address and
+// canonicalises the host, so the server_ip label is stable however the
+// server was spelled in accel-ppp.conf: "2001:DB8:0::1", "[2001:db8::1]" and
+// "[2001:db8::1]:1812" all yield "2001:db8::1".
+func parseRadiusAddr(addr string) string {
+	host := addr
+	// SplitHostPort rejects bare IPv6 literals ("too many colons") and
+	// bracketed ones without a port, which is what keeps them intact here.
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
 	}
-	parts := strings.Split(value, "/")
-	if len(parts) != want {
-		log.Printf("parser: expected %d fields in %q, got %d", want, value, len(parts))
-		return nil, false
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return ip.String()
 	}
-	out := make([]float64, want)
-	for i, p := range parts {
-		out[i] = atof(p)
-	}
-	return out, true
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// atof parses a numeric field of `show sessions`. An empty value yields 0
+// silently (accel-cmd legitimately omits fields); a non-empty value that fails
+// to parse yields 0 and ok=false, which ParseSessionsReport counts so malformed
+// output is visible to operators instead of masquerading as a real zero.
+// `show stat` values go through warner.atof instead, which records a Warning.
+func atof(value string) (f float64, ok bool) {
+	f, err := parseNumber(value)
+	return f, err == nil
 }
 
-// Helper functions to parse each section...
-func parseMainSection(stats *Stats, key, value string) {
+// Helper functions to parse each section. Each reports whether it recognised
+// key, so unrecognised lines can be kept in Stats.Unknown, and reports
+// malformed values of recognised keys to w.
+func parseMainSection(w *warner, stats *Stats, key, value string) bool {
 	switch key {
 	case "uptime":
-		stats.Uptime = parseUptime(value)
+		v, ok := parseUptime(value)
+		if !ok {
+			w.warn("cannot parse %q as uptime", value)
+		}
+		stats.Uptime = v
 	case "cpu":
-		stats.CPUPercent = parsePercentage(value)
+		stats.CPUPercent = w.parsePercentage(value)
 	case "mem(rss/virt)":
-		parseMemory(stats, value)
+		w.parseMemory(stats, value)
+	default:
+		return false
 	}
+	return true
 }
 
-func parseUptime(value string) float64 {
-	// Parse uptime in format "138.00:05:20" (days.hours:minutes:seconds)
-	parts := strings.Split(value, ".")
-	if len(parts) != 2 {
-		return 0
+// parseUptime parses an uptime in the format "138.00:05:20"
+// (days.hours:minutes:seconds). It reports ok=false for a non-empty value in
+// any other format; an empty value is 0.
+func parseUptime(value string) (seconds float64, ok bool) {
+	if value == "" {
+		return 0, true
 	}
-
-	days, err := strconv.ParseFloat(parts[0], 64)
-	if err != nil {
-		return 0
+	days, clock, found := strings.Cut(value, ".")
+	if !found {
+		return 0, false
 	}
-
-	timeParts := strings.Split(parts[1], ":")
+	timeParts := strings.Split(clock, ":")
 	if len(timeParts) != 3 {
-		return 0
+		return 0, false
 	}
-
-	hours := atof(timeParts[0])
-	minutes := atof(timeParts[1])
-	seconds := atof(timeParts[2])
-
-	return days*86400 + hours*3600 + minutes*60 + seconds
+	var total float64
+	for i, p := range append([]string{days}, timeParts...) {
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0, false
+		}
+		total += f * []float64{86400, 3600, 60, 1}[i]
+	}
+	return total, true
 }
 
-func parsePercentage(value string) float64 {
+func (w *warner) parsePercentage(value string) float64 {
 	// Example: "1.23%"
-	return atof(strings.TrimSuffix(value, "%"))
+	return w.atof(strings.TrimSuffix(value, "%"))
 }
 
-func parseMemory(stats *Stats, value string) {
+func (w *warner) parseMemory(stats *Stats, value string) {
 	// Example: "12345 / 67890 K"
-	if v, ok := fields(strings.TrimSuffix(value, " K"), 2); ok {
+	if v, ok := w.fields(strings.TrimSuffix(value, " K"), 2); ok {
 		stats.MemRSS = v[0]
 		stats.MemVirt = v[1]
 	}
 }
 
-func parseCoreSection(core *CoreStats, key, value string) {
+func parseCoreSection(w *warner, core *CoreStats, key, value string) bool {
 	switch key {
 	case "mempool(allocated/available)":
 		// Example: "1024 / 2048"
-		if v, ok := fields(value, 2); ok {
+		if v, ok := w.fields(value, 2); ok {
 			core.MempoolAllocated = v[0]
 			core.MempoolAvailable = v[1]
 		}
 	case "threads(count/active)":
-		if v, ok := fields(value, 2); ok {
+		if v, ok := w.fields(value, 2); ok {
 			core.ThreadCount = v[0]
 			core.ThreadActive = v[1]
 		}
 	case "context(count/sleep/pending)":
-		if v, ok := fields(value, 3); ok {
+		if v, ok := w.fields(value, 3); ok {
 			core.ContextCount = v[0]
 			core.ContextSleeping = v[1]
 			core.ContextPending = v[2]
 		}
 	case "md_handler(count/pending)":
-		if v, ok := fields(value, 2); ok {
+		if v, ok := w.fields(value, 2); ok {
 			core.MDHandlerCount = v[0]
 			core.MDHandlerPending = v[1]
 		}
 	case "timer(count/pending)":
-		if v, ok := fields(value, 2); ok {
+		if v, ok := w.fields(value, 2); ok {
 			core.TimerCount = v[0]
 			core.TimerPending = v[1]
 		}
+	default:
+		return false
 	}
+	return true
 }
 
-func parseSessionsSection(sessions *SessionStats, key, value string) {
-	f := atof(value)
+func parseSessionsSection(w *warner, sessions *SessionStats, key, value string) bool {
+	var dst *float64
 	switch key {
 	case "starting":
-		sessions.Starting = f
+		dst = &sessions.Starting
 	case "active":
-		sessions.Active = f
+		dst = &sessions.Active
 	case "finishing":
-		sessions.Finishing = f
+		dst = &sessions.Finishing
+	default:
+		return false
 	}
+	*dst = w.atof(value)
+	return true
 }
 
-func parsePPPoESection(pppoe *PPPoEStats, key, value string) {
-	f := atof(value)
+func parsePPPoESection(w *warner, pppoe *PPPoEStats, key, value string) bool {
+	var dst *float64
 	switch key {
 	case "starting":
-		pppoe.Starting = f
+		dst = &pppoe.Starting
 	case "active":
-		pppoe.Active = f
+		dst = &pppoe.Active
 	case "delayed PADO":
-		pppoe.DelayedPADO = f
+		dst = &pppoe.DelayedPADO
 	case "recv PADI":
-		pppoe.RecvPADI = f
+		dst = &pppoe.RecvPADI
 	case "drop PADI":
-		pppoe.DropPADI = f
+		dst = &pppoe.DropPADI
 	case "sent PADO":
-		pppoe.SentPADO = f
+		dst = &pppoe.SentPADO
 	case "recv PADR":
-		pppoe.RecvPADR = f
+		dst = &pppoe.RecvPADR
 	case "recv PADR(dup)":
-		pppoe.RecvPADRDup = f
+		dst = &pppoe.RecvPADRDup
 	case "sent PADS":
-		pppoe.SentPADS = f
+		dst = &pppoe.SentPADS
 	case "filtered":
-		pppoe.Filtered = f
+		dst = &pppoe.Filtered
+	default:
+		return false
+	}
+	*dst = w.atof(value)
+	return true
+}
+
+func parseIPoESection(w *warner, ipoe *IPoEStats, key, value string) bool {
+	var dst *float64
+	switch key {
+	case "starting":
+		dst = &ipoe.Starting
+	case "active":
+		dst = &ipoe.Active
+	case "delayed offers":
+		dst = &ipoe.DelayedOffers
+	default:
+		return false
+	}
+	*dst = w.atof(value)
+	return true
+}
+
+func parseL2TPSection(w *warner, l2tp *L2TPStats, subsection, key, value string) bool {
+	var c *L2TPCounters
+	switch subsection {
+	case "tunnels":
+		c = &l2tp.Tunnels
+	case "sessions (control channels)":
+		// Older releases are assumed to call it "sessions" (see Profiles).
+		c = &l2tp.Sessions
+	default:
+		return false
+	}
+	var dst *float64
+	switch key {
+	case "starting":
+		dst = &c.Starting
+	case "active":
+		dst = &c.Active
+	case "finishing":
+		dst = &c.Finishing
+	default:
+		return false
+	}
+	*dst = w.atof(value)
+	return true
+}
+
+func parsePPTPSection(w *warner, pptp *PPTPStats, key, value string) bool {
+	var dst *float64
+	switch key {
+	case "starting":
+		dst = &pptp.Starting
+	case "active":
+		dst = &pptp.Active
+	default:
+		return false
+	}
+	*dst = w.atof(value)
+	return true
+}
+
+func parseSSTPSection(w *warner, sstp *SSTPStats, key, value string) bool {
+	var dst *float64
+	switch key {
+	case "starting":
+		dst = &sstp.Starting
+	case "active":
+		dst = &sstp.Active
+	default:
+		return false
 	}
+	*dst = w.atof(value)
+	return true
 }
 
-func parseRadiusSection(radius *RadiusStats, key, value string) {
+func parseRadiusSection(w *warner, radius *RadiusStats, key, value string) bool {
 	switch key {
 	case "state":
 		radius.State = value // State is a string
 	case "fail count":
-		radius.FailCount = atof(value)
+		radius.FailCount = w.atof(value)
 	case "request count":
-		radius.RequestCount = atof(value)
+		radius.RequestCount = w.atof(value)
 	case "queue length":
-		radius.QueueLength = atof(value)
+		radius.QueueLength = w.atof(value)
 	case "auth sent":
-		radius.AuthSent = atof(value)
+		radius.AuthSent = w.atof(value)
 	case "auth lost(total/5m/1m)":
-		if v, ok := fields(value, 3); ok {
+		if v, ok := w.fields(value, 3); ok {
 			radius.AuthLostTotal = v[0]
 			radius.AuthLost5m = v[1]
 			radius.AuthLost1m = v[2]
 		}
 	case "auth avg time(5m/1m)":
-		if v, ok := fields(value, 2); ok {
+		if v, ok := w.fields(value, 2); ok {
 			radius.AuthAvgTime5m = v[0]
 			radius.AuthAvgTime1m = v[1]
 		}
 	case "acct sent":
-		radius.AcctSent = atof(value)
+		radius.AcctSent = w.atof(value)
 	case "acct lost(total/5m/1m)":
-		if v, ok := fields(value, 3); ok {
+		if v, ok := w.fields(value, 3); ok {
 			radius.AcctLostTotal = v[0]
 			radius.AcctLost5m = v[1]
 			radius.AcctLost1m = v[2]
 		}
 	case "acct avg time(5m/1m)":
-		if v, ok := fields(value, 2); ok {
+		if v, ok := w.fields(value, 2); ok {
 			radius.AcctAvgTime5m = v[0]
 			radius.AcctAvgTime1m = v[1]
 		}
 	case "interim sent":
-		radius.InterimSent = atof(value)
+		radius.InterimSent = w.atof(value)
 	case "interim lost(total/5m/1m)":
-		if v, ok := fields(value, 3); ok {
+		if v, ok := w.fields(value, 3); ok {
 			radius.InterimLostTotal = v[0]
 			radius.InterimLost5m = v[1]
 			radius.InterimLost1m = v[2]
 		}
 	case "interim avg time(5m/1m)":
-		if v, ok := fields(value, 2); ok {
+		if v, ok := w.fields(value, 2); ok {
 			radius.InterimAvgTime5m = v[0]
 			radius.InterimAvgTime1m = v[1]
 		}
+	default:
+		return false
 	}
+	return true
 }
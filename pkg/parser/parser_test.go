@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"testing"
 	"time"
 )
@@ -63,8 +64,9 @@ func TestCollectStatsExecError(t *testing.T) {
 
 // sampleStat is a representative `accel-cmd show stat` capture exercising every
 // section the parser understands: the unlabelled main block, core, sessions,
-// pppoe, and a single radius server. Indentation is intentional — the parser
-// trims each line, so leading whitespace must not change the result.
+// pppoe, ipoe, l2tp, pptp, sstp, and a single radius server. Indentation
+// matters as in real output: an indented header (l2tp's "tunnels:") opens a
+// block nested in the current section rather than a new section.
 const sampleStat = `uptime: 138.00:05:20
 cpu: 1.50%
 mem(rss/virt): 12345 / 67890 K
@@ -89,6 +91,29 @@ pppoe:
   recv PADR(dup): 2
   sent PADS: 988
   filtered: 1
+ipoe:
+  starting: 2
+  active: 40
+  delayed offers: 6
+l2tp:
+  tunnels:
+    starting: 1
+    active: 12
+    finishing: 0
+  sessions (control channels):
+    starting: 2
+    active: 30
+    finishing: 1
+  sessions (data channels):
+    starting: 3
+    active: 29
+    finishing: 4
+pptp:
+  starting: 1
+  active: 8
+sstp:
+  starting: 0
+  active: 5
 radius(1, 10.0.0.1):
   state: active
   fail count: 0
@@ -113,9 +138,9 @@ func wantEq(t *testing.T, name string, got, want float64) {
 }
 
 func TestParseStatsMainAndCore(t *testing.T) {
-	st, err := parseStats(sampleStat)
+	st, err := ParseStats(sampleStat)
 	if err != nil {
-		t.Fatalf("parseStats: %v", err)
+		t.Fatalf("ParseStats: %v", err)
 	}
 	wantEq(t, "Uptime", st.Uptime, 138*86400+5*60+20) // 138d 00:05:20
 	wantEq(t, "CPUPercent", st.CPUPercent, 1.50)
@@ -135,9 +160,9 @@ func TestParseStatsMainAndCore(t *testing.T) {
 }
 
 func TestParseStatsSessionsAndPPPoE(t *testing.T) {
-	st, err := parseStats(sampleStat)
+	st, err := ParseStats(sampleStat)
 	if err != nil {
-		t.Fatalf("parseStats: %v", err)
+		t.Fatalf("ParseStats: %v", err)
 	}
 	wantEq(t, "Sessions.Starting", st.Sessions.Starting, 1)
 	wantEq(t, "Sessions.Active", st.Sessions.Active, 100)
@@ -152,10 +177,88 @@ func TestParseStatsSessionsAndPPPoE(t *testing.T) {
 	wantEq(t, "PPPoE.Filtered", st.PPPoE.Filtered, 1)
 }
 
+func TestParseStatsIPoE(t *testing.T) {
+	st, err := ParseStats(sampleStat)
+	if err != nil {
+		t.Fatalf("ParseStats: %v", err)
+	}
+	wantEq(t, "IPoE.Starting", st.IPoE.Starting, 2)
+	wantEq(t, "IPoE.Active", st.IPoE.Active, 40)
+	wantEq(t, "IPoE.DelayedOffers", st.IPoE.DelayedOffers, 6)
+	// The ipoe block must not leak into the pppoe counters it follows.
+	wantEq(t, "PPPoE.Active", st.PPPoE.Active, 90)
+}
+
+func TestParseStatsL2TP(t *testing.T) {
+	st, err := ParseStats(sampleStat)
+	if err != nil {
+		t.Fatalf("ParseStats: %v", err)
+	}
+	wantEq(t, "L2TP.Tunnels.Starting", st.L2TP.Tunnels.Starting, 1)
+	wantEq(t, "L2TP.Tunnels.Active", st.L2TP.Tunnels.Active, 12)
+	wantEq(t, "L2TP.Tunnels.Finishing", st.L2TP.Tunnels.Finishing, 0)
+	wantEq(t, "L2TP.Sessions.Starting", st.L2TP.Sessions.Starting, 2)
+	wantEq(t, "L2TP.Sessions.Active", st.L2TP.Sessions.Active, 30)
+	wantEq(t, "L2TP.Sessions.Finishing", st.L2TP.Sessions.Finishing, 1)
+	// The nested "sessions" blocks must not be taken for the top-level one.
+	wantEq(t, "Sessions.Active", st.Sessions.Active, 100)
+	wantEq(t, "Sessions.Finishing", st.Sessions.Finishing, 2)
+}
+
+// TestParseStatsL2TPUnknownKey verifies a key the parser does not know inside
+// an l2tp block is left to Unknown without a warning, even when its value is
+// not a number, so it neither counts as a parse error nor fails strict mode.
+func TestParseStatsL2TPUnknownKey(t *testing.T) {
+	in := `uptime: 0.01:00:00
+l2tp:
+  tunnels:
+    mode: lns
+    active: 3
+`
+	st, err := ParseStats(in)
+	if err != nil {
+		t.Fatalf("ParseStats: %v", err)
+	}
+	if len(st.Warnings) != 0 {
+		t.Errorf("Warnings = %+v, want none", st.Warnings)
+	}
+	wantEq(t, "L2TP.Tunnels.Active", st.L2TP.Tunnels.Active, 3)
+}
+
+// TestParseStatsL2TPLegacy covers releases that print a single "sessions:"
+// block under l2tp instead of separate control and data channels.
+func TestParseStatsL2TPLegacy(t *testing.T) {
+	in := `uptime: 0.01:00:00
+l2tp:
+  tunnels:
+    active: 3
+  sessions:
+    active: 7
+`
+	st, err := ParseStats(in)
+	if err != nil {
+		t.Fatalf("ParseStats: %v", err)
+	}
+	wantEq(t, "L2TP.Tunnels.Active", st.L2TP.Tunnels.Active, 3)
+	wantEq(t, "L2TP.Sessions.Active", st.L2TP.Sessions.Active, 7)
+	wantEq(t, "Sessions.Active", st.Sessions.Active, 0)
+}
+
+func TestParseStatsPPTPAndSSTP(t *testing.T) {
+	st, err := ParseStats(sampleStat)
+	if err != nil {
+		t.Fatalf("ParseStats: %v", err)
+	}
+	wantEq(t, "PPTP.Starting", st.PPTP.Starting, 1)
+	wantEq(t, "PPTP.Active", st.PPTP.Active, 8)
+	wantEq(t, "SSTP.Starting", st.SSTP.Starting, 0)
+	wantEq(t, "SSTP.Active", st.SSTP.Active, 5)
+}
+
 func TestParseStatsRadius(t *testing.T) {
-	st, err := parseStats(sampleStat)
+	st, err := ParseStats(sampleStat)
 	if err != nil {
-		t.Fatalf("parseStats: %v", err)
+		t.Fatalf("ParseStats: %v", err)
 	}
 	if len(st.RadiusServers) != 1 {
 		t.Fatalf("RadiusServers = %d, want 1", len(st.RadiusServers))
@@ -185,16 +288,17 @@ func TestParseStatsRadius(t *testing.T) {
 // TestParseStatsMultipleRadius verifies servers are keyed by id and a second
 // server does not clobber the first.
 func TestParseStatsMultipleRadius(t *testing.T) {
-	in := `radius(1, 10.0.0.1):
+	in := `uptime: 0.01:00:00
+radius(1, 10.0.0.1):
   state: active
   auth sent: 5
 radius(2, 10.0.0.2):
   state: failed
   auth sent: 9
 `
-	st, err := parseStats(in)
+	st, err := ParseStats(in)
 	if err != nil {
-		t.Fatalf("parseStats: %v", err)
+		t.Fatalf("ParseStats: %v", err)
 	}
 	if len(st.RadiusServers) != 2 {
 		t.Fatalf("RadiusServers = %d, want 2", len(st.RadiusServers))
@@ -207,13 +311,81 @@ radius(2, 10.0.0.2):
 	}
 }
 
-func TestParseStatsEmpty(t *testing.T) {
-	st, err := parseStats("")
+// TestParseStatsRadiusHeaderForms verifies servers configured by IPv6 literal,
+// bracketed address, address with port, or hostname are all parsed.
+func TestParseStatsRadiusHeaderForms(t *testing.T) {
+	in := `uptime: 0.01:00:00
+radius(1, 10.0.0.1):
+  auth sent: 1
+radius(2, 2001:db8::1):
+  auth sent: 2
+radius(3, [2001:db8::2]:1812):
+  auth sent: 3
+radius(4, Radius.Example.COM):
+  auth sent: 4
+radius(5, 10.0.0.5:1645):
+  auth sent: 5
+`
+	st, err := ParseStats(in)
 	if err != nil {
-		t.Fatalf("parseStats: %v", err)
+		t.Fatalf("ParseStats: %v", err)
+	}
+	want := map[string]struct {
+		ip   string
+		sent float64
+	}{
+		"1": {"10.0.0.1", 1},
+		"2": {"2001:db8::1", 2},
+		"3": {"2001:db8::2", 3},
+		"4": {"radius.example.com", 4},
+		"5": {"10.0.0.5", 5},
+	}
+	if len(st.RadiusServers) != len(want) {
+		t.Fatalf("RadiusServers = %d, want %d: %+v", len(st.RadiusServers), len(want), st.RadiusServers)
+	}
+	for id, w := range want {
+		rs := st.RadiusServers[id]
+		if rs.IP != w.ip {
+			t.Errorf("server %s: IP=%q, want %q", id, rs.IP, w.ip)
+		}
+		wantEq(t, "server "+id+" AuthSent", rs.AuthSent, w.sent)
+	}
+}
+
+func TestParseRadiusAddr(t *testing.T) {
+	tests := []struct {
+		in, host string
+	}{
+		{"10.0.0.1", "10.0.0.1"},
+		{"10.0.0.1:1812", "10.0.0.1"},
+		{"2001:db8::1", "2001:db8::1"},
+		{"2001:DB8:0:0::1", "2001:db8::1"}, // canonicalised
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"[2001:db8::1]:1812", "2001:db8::1"},
+		{"radius.example.com", "radius.example.com"},
+		{"RADIUS.example.com.", "radius.example.com"},
+		{"radius.example.com:1812", "radius.example.com"},
+	}
+	for _, tt := range tests {
+		if host := parseRadiusAddr(tt.in); host != tt.host {
+			t.Errorf("parseRadiusAddr(%q) = %q; want %q", tt.in, host, tt.host)
+		}
 	}
-	if st == nil {
-		t.Fatal("parseStats returned nil stats")
+}
+
+// TestParseStatsNotShowStat verifies output without an uptime line — nothing
+// at all, or a CLI error reply — is a parse error rather than all zeros.
+func TestParseStatsNotShowStat(t *testing.T) {
+	for _, in := range []string{"", "\r\n", "invalid command\r\n", "SSH-2.0-OpenSSH_9.6\r\n"} {
+		st, err := ParseStats(in)
+		if FailureReason(err) != ReasonParse {
+			t.Errorf("ParseStats(%q) = %+v, %v; want a parse error", in, st, err)
+		}
+	}
+
+	st, err := ParseStats("uptime: 0.00:00:01\n")
+	if err != nil {
+		t.Fatalf("ParseStats of an idle node: %v", err)
 	}
 	if len(st.RadiusServers) != 0 {
 		t.Errorf("RadiusServers = %d, want 0", len(st.RadiusServers))
@@ -224,40 +396,52 @@ func TestParseStatsEmpty(t *testing.T) {
 // without aborting the parse or corrupting sibling fields. A bad sub-field in a
 // "/"-delimited value becomes 0 while its valid neighbours still parse.
 func TestParseStatsMalformed(t *testing.T) {
-	in := `radius(1, 10.0.0.1):
+	in := `uptime: 0.01:00:00
+radius(1, 10.0.0.1):
   state: active
   auth sent: notanumber
   auth lost(total/5m/1m): bad / 1 / 0
 `
-	st, err := parseStats(in)
+	st, err := ParseStats(in)
 	if err != nil {
-		t.Fatalf("parseStats: %v", err)
+		t.Fatalf("ParseStats: %v", err)
 	}
 	rs := st.RadiusServers["1"]
 	wantEq(t, "AuthSent", rs.AuthSent, 0)           // unparseable scalar -> 0
 	wantEq(t, "AuthLostTotal", rs.AuthLostTotal, 0) // bad sub-field -> 0
 	wantEq(t, "AuthLost5m", rs.AuthLost5m, 1)       // neighbours still parse
 	wantEq(t, "AuthLost1m", rs.AuthLost1m, 0)
+
+	// Each malformed line is reported once, however many fields are bad.
+	want := []Warning{
+		{Section: "radius(1, 10.0.0.1)", Key: "auth sent", Value: "notanumber"},
+		{Section: "radius(1, 10.0.0.1)", Key: "auth lost(total/5m/1m)", Value: "bad / 1 / 0"},
+	}
+	if !slices.Equal(st.Warnings, want) {
+		t.Errorf("Warnings = %+v, want %+v", st.Warnings, want)
+	}
 }
 
 func TestParseUptime(t *testing.T) {
 	tests := []struct {
-		name string
-		in   string
-		want float64
+		name   string
+		in     string
+		want   float64
+		wantOK bool
 	}{
-		{"days and time", "138.00:05:20", 138*86400 + 5*60 + 20},
-		{"zero", "0.00:00:00", 0},
-		{"hours only", "1.02:00:00", 86400 + 2*3600},
-		{"missing dot", "00:05:20", 0},
-		{"bad days", "abc.00:05:20", 0},
-		{"short time", "1.05:20", 0},
-		{"empty", "", 0},
+		{"days and time", "138.00:05:20", 138*86400 + 5*60 + 20, true},
+		{"zero", "0.00:00:00", 0, true},
+		{"hours only", "1.02:00:00", 86400 + 2*3600, true},
+		{"missing dot", "00:05:20", 0, false},
+		{"bad days", "abc.00:05:20", 0, false},
+		{"bad seconds", "1.00:05:xx", 0, false},
+		{"short time", "1.05:20", 0, false},
+		{"empty", "", 0, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := parseUptime(tt.in); got != tt.want {
-				t.Errorf("parseUptime(%q) = %v, want %v", tt.in, got, tt.want)
+			if got, ok := parseUptime(tt.in); got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseUptime(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.wantOK)
 			}
 		})
 	}
@@ -274,7 +458,7 @@ func TestParsePercentage(t *testing.T) {
 		{"bad%", 0},
 	}
 	for _, tt := range tests {
-		if got := parsePercentage(tt.in); got != tt.want {
+		if got := (&warner{}).parsePercentage(tt.in); got != tt.want {
 			t.Errorf("parsePercentage(%q) = %v, want %v", tt.in, got, tt.want)
 		}
 	}
@@ -282,13 +466,13 @@ func TestParsePercentage(t *testing.T) {
 
 func TestParseMemory(t *testing.T) {
 	st := &Stats{}
-	parseMemory(st, "12345 / 67890 K")
+	(&warner{}).parseMemory(st, "12345 / 67890 K")
 	wantEq(t, "MemRSS", st.MemRSS, 12345)
 	wantEq(t, "MemVirt", st.MemVirt, 67890)
 
 	// Malformed input leaves the values untouched (not NaN).
 	bad := &Stats{}
-	parseMemory(bad, "garbage")
+	(&warner{}).parseMemory(bad, "garbage")
 	if math.IsNaN(bad.MemRSS) || bad.MemRSS != 0 {
 		t.Errorf("MemRSS after bad parse = %v, want 0", bad.MemRSS)
 	}
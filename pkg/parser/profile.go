@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"context"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// Profile adapts the parser to the `show stat` layout of a range of accel-ppp
+// releases. The section parsers are written against the newest layout; a
+// profile maps the block headers an older release uses onto it. Keys are
+// matched by name, so a release reordering them needs no mapping, and no
+// release has been seen renaming one, so profiles only map headers.
+type Profile struct {
+	// Name identifies the profile, e.g. in logs.
+	Name string
+	// MinVersion is the oldest accel-ppp release the profile applies to; it
+	// applies up to the next profile's MinVersion.
+	MinVersion string
+	// Headers maps block headers as the release prints them to the ones the
+	// parser expects. Nested blocks are keyed by their "section/subsection"
+	// path, e.g. "l2tp/sessions".
+	Headers map[string]string
+}
+
+// Profiles lists the known layouts, oldest first. Add a profile (and a
+// capture under testdata) when a release changes the layout. The testdata
+// samples are hand-written, so the layouts below are unverified against real
+// captures.
+var Profiles = []*Profile{
+	{
+		Name:       "1.11",
+		MinVersion: "1.11.0",
+		// Current releases print l2tp's session counters under "sessions
+		// (control channels)"; older ones are assumed to print a plain
+		// "sessions" block, and 1.12 to be where that changed. Neither the
+		// old spelling nor the boundary has been checked against a capture.
+		Headers: map[string]string{
+			"l2tp/sessions": "l2tp/sessions (control channels)",
+		},
+	},
+	{
+		Name:       "1.12",
+		MinVersion: "1.12.0",
+	},
+}
+
+// DefaultProfile is used when the accel-ppp version is unknown (or did not
+// parse). It accepts the spellings of every profile, which is right as long
+// as no release reuses an older spelling for a different value.
+var DefaultProfile = mergeProfiles("default", Profiles)
+
+func mergeProfiles(name string, profiles []*Profile) *Profile {
+	merged := &Profile{Name: name, Headers: map[string]string{}}
+	for _, p := range profiles {
+		maps.Copy(merged.Headers, p.Headers)
+	}
+	return merged
+}
+
+// ProfileFor returns the profile for an accel-ppp version as reported by
+// `show version` (e.g. "1.12.0-168-ge7d7e2a"): the newest profile whose
+// MinVersion is not after it. Releases older than every profile get the
+// oldest one; an empty or unparseable version gets DefaultProfile.
+func ProfileFor(version string) *Profile {
+	v, ok := parseVersionNumbers(version)
+	if !ok {
+		return DefaultProfile
+	}
+	best := Profiles[0]
+	for _, p := range Profiles[1:] {
+		if from, _ := parseVersionNumbers(p.MinVersion); compareVersions(v, from) >= 0 {
+			best = p
+		}
+	}
+	return best
+}
+
+// parseVersionNumbers extracts major, minor and patch from a version such as
+// "1.12.0-168-ge7d7e2a". Missing components are 0.
+func parseVersionNumbers(version string) ([3]int, bool) {
+	var out [3]int
+	// Drop git describe's "-<commits>-g<hash>" and any other suffix.
+	version, _, _ = strings.Cut(strings.TrimPrefix(version, "v"), "-")
+	parts := strings.Split(version, ".")
+	if len(parts) > len(out) {
+		return out, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return out, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
+
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return 0
+}
+
+// header maps a block header (a "section/subsection" path for nested blocks)
+// to the one the parser expects.
+func (p *Profile) header(path string) string {
+	if h, ok := p.Headers[path]; ok {
+		return h
+	}
+	return path
+}
+
+type profileKey struct{}
+
+// WithProfile returns a copy of ctx carrying p, for Sources to parse with
+// (see ProfileFromContext).
+func WithProfile(ctx context.Context, p *Profile) context.Context {
+	return context.WithValue(ctx, profileKey{}, p)
+}
+
+// ProfileFromContext returns the profile carried by ctx, or DefaultProfile.
+func ProfileFromContext(ctx context.Context) *Profile {
+	if p, ok := ctx.Value(profileKey{}).(*Profile); ok && p != nil {
+		return p
+	}
+	return DefaultProfile
+}
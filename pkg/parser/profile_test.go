@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProfileFor(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"1.12.0-168-ge7d7e2a", "1.12"},
+		{"1.13.1", "1.12"},
+		{"1.11.2", "1.11"},
+		{"1.10.0", "1.11"}, // older than every profile: the oldest applies
+		{"v1.12.0", "1.12"},
+		{"", "default"},
+		{"unknown", "default"},
+	}
+	for _, tt := range tests {
+		if got := ProfileFor(tt.version).Name; got != tt.want {
+			t.Errorf("ProfileFor(%q) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
+
+// TestParseStatsCorpus parses every capture under testdata with the profile
+// its file name selects.
+func TestParseStatsCorpus(t *testing.T) {
+	// Spot checks per capture: values whose parsing differs between
+	// layouts, plus a couple that do not.
+	type check struct {
+		name string
+		get  func(*Stats) float64
+		want float64
+	}
+	checks := map[string][]check{
+		"1.11": {
+			{"Sessions.Active", func(s *Stats) float64 { return s.Sessions.Active }, 2514},
+			{"L2TP.Sessions.Active", func(s *Stats) float64 { return s.L2TP.Sessions.Active }, 18},
+			{"Radius[2].AcctLostTotal", func(s *Stats) float64 { return s.RadiusServers["2"].AcctLostTotal }, 40},
+		},
+		"1.12": {
+			{"Sessions.Active", func(s *Stats) float64 { return s.Sessions.Active }, 5120},
+			{"L2TP.Sessions.Active", func(s *Stats) float64 { return s.L2TP.Sessions.Active }, 31},
+			{"IPoE.Active", func(s *Stats) float64 { return s.IPoE.Active }, 212},
+			{"Radius[1].InterimLost1m", func(s *Stats) float64 { return s.RadiusServers["1"].InterimLost1m }, 1},
+		},
+	}
+
+	files, err := filepath.Glob(filepath.Join("testdata", "show-stat-*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(Profiles) {
+		t.Errorf("found %d captures, want one per profile (%d)", len(files), len(Profiles))
+	}
+	for _, file := range files {
+		version := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "show-stat-"), ".txt")
+		t.Run(version, func(t *testing.T) {
+			out, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := ProfileFor(version)
+			if p.Name != version {
+				t.Fatalf("ProfileFor(%q) = %q, want the capture's own profile", version, p.Name)
+			}
+			st, err := ParseStatsProfile(string(out), p)
+			if err != nil {
+				t.Fatalf("ParseStatsProfile: %v", err)
+			}
+			if len(st.Warnings) != 0 {
+				t.Errorf("Warnings = %+v, want none", st.Warnings)
+			}
+			if len(checks[version]) == 0 {
+				t.Errorf("no spot checks for capture %s", file)
+			}
+			for _, c := range checks[version] {
+				wantEq(t, c.name, c.get(st), c.want)
+			}
+		})
+	}
+}
+
+// TestParseStatsProfileHeaders verifies a profile's header mapping applies
+// only under that profile: the 1.12 layout has no plain l2tp "sessions"
+// block, so it is left to Unknown rather than read as control channels.
+func TestParseStatsProfileHeaders(t *testing.T) {
+	in := "uptime: 0.01:00:00\nl2tp:\n  sessions:\n    active: 7\n"
+
+	st, err := ParseStatsProfile(in, ProfileFor("1.11.0"))
+	if err != nil {
+		t.Fatalf("ParseStatsProfile: %v", err)
+	}
+	wantEq(t, "1.11 L2TP.Sessions.Active", st.L2TP.Sessions.Active, 7)
+
+	if st, err = ParseStatsProfile(in, ProfileFor("1.12.0")); err != nil {
+		t.Fatalf("ParseStatsProfile: %v", err)
+	}
+	wantEq(t, "1.12 L2TP.Sessions.Active", st.L2TP.Sessions.Active, 0)
+	if len(st.Unknown) != 1 || st.Unknown[0].Section != "l2tp/sessions" {
+		t.Errorf("Unknown = %+v, want the l2tp/sessions line", st.Unknown)
+	}
+}
+
+func TestProfileFromContext(t *testing.T) {
+	if p := ProfileFromContext(context.Background()); p != DefaultProfile {
+		t.Errorf("ProfileFromContext(empty) = %q, want default", p.Name)
+	}
+	p := ProfileFor("1.11.0")
+	if got := ProfileFromContext(WithProfile(context.Background(), p)); got != p {
+		t.Errorf("ProfileFromContext = %q, want %q", got.Name, p.Name)
+	}
+}
@@ -0,0 +1,184 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Session is one row of `accel-cmd show sessions`.
+type Session struct {
+	SID         string
+	IfName      string
+	InboundIf   string
+	Username    string
+	IP          string
+	Type        string
+	State       string
+	CallingSID  string
+	ServiceName string
+	Uptime      float64 // seconds
+	RxBytes     float64
+	TxBytes     float64
+}
+
+// SessionColumns is the column list requested from `show sessions`. The raw
+// byte columns are used because rx-bytes/tx-bytes are rounded for humans
+// ("1.2 MiB"); ParseSessionsReport accepts either form.
+var SessionColumns = []string{
+	"sid", "ifname", "inbound-if", "username", "ip", "type", "state", "uptime",
+	"rx-bytes-raw", "tx-bytes-raw", "calling-sid",
+}
+
+// ServiceNameColumn is the `show sessions` column filling
+// Session.ServiceName. Only accel-ppp's pppoe module provides it, and asking
+// for it elsewhere fails the whole command, so it is not in SessionColumns.
+const ServiceNameColumn = "service-name"
+
+// requiredSessionColumns must appear in the header of `show sessions`
+// output. Without them the output is an error message (e.g. "unknown
+// column"), not a table, and must not pass for zero sessions.
+var requiredSessionColumns = []string{"sid", "ifname"}
+
+// SessionsArgs returns the accel-cmd arguments listing columns.
+func SessionsArgs(columns []string) []string {
+	return []string{"show", "sessions", strings.Join(columns, ",")}
+}
+
+// SessionProblems counts what ParseSessionsReport could not read. On nodes with
+// tens of thousands of sessions a format change affects every row, so the
+// problems are summed up for the caller to report once per table.
+type SessionProblems struct {
+	// SkippedRows had a different number of cells than the header.
+	SkippedRows int
+	// MalformedValues were non-empty numeric cells that did not parse and
+	// were read as 0.
+	MalformedValues int
+}
+
+// ParseSessionsReport parses the "|"-separated table printed by `show
+// sessions`, returning the problems met for the caller to log. Columns are
+// matched by header name, so their order and any columns the Session type
+// does not know about are irrelevant.
+func ParseSessionsReport(output string) ([]Session, SessionProblems, error) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	// Rows can be wide (long usernames, calling-sid); allow up to 1 MiB.
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	var header []string
+	var sessions []Session
+	var problems SessionProblems
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue
+		}
+		cells := splitRow(line)
+		if header == nil {
+			header = cells
+			continue
+		}
+		if len(cells) != len(header) {
+			// Most likely a "|" inside a value (e.g. a username); skip the
+			// row rather than lose the whole table.
+			problems.SkippedRows++
+			continue
+		}
+		var s Session
+		for i, col := range header {
+			if !setSessionColumn(&s, col, cells[i]) {
+				problems.MalformedValues++
+			}
+		}
+		sessions = append(sessions, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, problems, &ParseError{Err: err}
+	}
+	for _, col := range requiredSessionColumns {
+		if !slices.Contains(header, col) {
+			return nil, problems, &ParseError{Err: fmt.Errorf("no %s column, not show sessions output", col)}
+		}
+	}
+	return sessions, problems, nil
+}
+
+// splitRow splits a table row on "|" and trims each cell.
+func splitRow(line string) []string {
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+// setSessionColumn stores the cell of column in s. It reports false for a
+// numeric cell that did not parse, which is stored as 0.
+func setSessionColumn(s *Session, column, value string) bool {
+	ok := true
+	switch column {
+	case "sid":
+		s.SID = value
+	case "ifname":
+		s.IfName = value
+	case "inbound-if":
+		s.InboundIf = value
+	case "username":
+		s.Username = value
+	case "ip":
+		s.IP = value
+	case "type":
+		s.Type = value
+	case "state":
+		s.State = value
+	case "calling-sid":
+		s.CallingSID = value
+	case "service-name":
+		s.ServiceName = value
+	case "uptime":
+		s.Uptime, ok = parseSessionUptime(value)
+	case "uptime-raw":
+		s.Uptime, ok = atof(value)
+	case "rx-bytes", "rx-bytes-raw":
+		s.RxBytes, ok = parseBytes(value)
+	case "tx-bytes", "tx-bytes-raw":
+		s.TxBytes, ok = parseBytes(value)
+	}
+	return ok
+}
+
+// parseSessionUptime parses a session uptime, which accel-ppp prints as
+// "hh:mm:ss" and prefixes with "days." once a session is older than a day.
+// Like atof it reports ok=false for a malformed non-empty value.
+func parseSessionUptime(value string) (float64, bool) {
+	if value != "" && !strings.Contains(value, ".") {
+		value = "0." + value
+	}
+	return parseUptime(value)
+}
+
+// byteUnits maps the suffixes accel-ppp uses for human-readable byte counts
+// to their (binary) multipliers.
+var byteUnits = map[string]float64{
+	"B":   1,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+}
+
+// parseBytes parses a raw byte count or a human-readable one like "1.2 MiB".
+// Like atof it reports ok=false for a malformed non-empty value.
+func parseBytes(value string) (float64, bool) {
+	num, unit, found := strings.Cut(strings.TrimSpace(value), " ")
+	if !found {
+		return atof(num)
+	}
+	mult, known := byteUnits[strings.TrimSpace(unit)]
+	if !known {
+		return atof(value)
+	}
+	f, ok := atof(num)
+	return f * mult, ok
+}
@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+)
+
+// sampleSessions is a `show sessions` capture with the columns in
+// SessionColumns and ServiceNameColumn, plus a human-readable byte column to
+// exercise unit parsing.
+const sampleSessions = ` sid              | ifname | inbound-if | username |     ip     | type  | state  |   uptime   | rx-bytes-raw | tx-bytes-raw | calling-sid       | service-name | rx-bytes
+------------------+--------+------------+----------+------------+-------+--------+------------+--------------+--------------+-------------------+--------------+---------
+ a1b2c3d4e5f60001 | ppp0   | eth0.100   | alice    | 10.0.0.2   | pppoe | active | 01:02:03   | 1000         | 2000         | aa:bb:cc:dd:ee:01 | internet     | 1.0 KiB
+ a1b2c3d4e5f60002 | ipoe1  | eth0.200   | bob      | 10.0.0.3   | ipoe  | active | 2.00:00:10 | 3000         | 4000         | aa:bb:cc:dd:ee:02 |              | 2.5 MiB
+ a1b2c3d4e5f60003 | ppp1   | eth0.100   |          |            | pppoe | start  | 00:00:05   | 0            | 0            | aa:bb:cc:dd:ee:03 | internet     | 0 B
+`
+
+func TestParseSessions(t *testing.T) {
+	got, problems, err := ParseSessionsReport(sampleSessions)
+	if err != nil {
+		t.Fatalf("ParseSessionsReport: %v", err)
+	}
+	if problems != (SessionProblems{}) {
+		t.Errorf("problems = %+v, want none", problems)
+	}
+	if len(got) != 3 {
+		t.Fatalf("sessions = %d, want 3", len(got))
+	}
+	s := got[0]
+	if s.SID != "a1b2c3d4e5f60001" || s.IfName != "ppp0" || s.InboundIf != "eth0.100" || s.Username != "alice" || s.IP != "10.0.0.2" ||
+		s.Type != "pppoe" || s.State != "active" || s.CallingSID != "aa:bb:cc:dd:ee:01" || s.ServiceName != "internet" {
+		t.Errorf("session 0 = %+v", s)
+	}
+	wantEq(t, "Uptime", s.Uptime, 3600+2*60+3)
+	// rx-bytes (human readable) follows rx-bytes-raw in the header and wins.
+	wantEq(t, "RxBytes", s.RxBytes, 1024)
+	wantEq(t, "TxBytes", s.TxBytes, 2000)
+
+	wantEq(t, "session 1 Uptime", got[1].Uptime, 2*86400+10)
+	wantEq(t, "session 1 RxBytes", got[1].RxBytes, 2.5*(1<<20))
+	if got[1].ServiceName != "" {
+		t.Errorf("session 1 ServiceName = %q, want empty", got[1].ServiceName)
+	}
+	if got[2].Username != "" || got[2].State != "start" {
+		t.Errorf("session 2 = %+v", got[2])
+	}
+}
+
+func TestParseSessionsEmpty(t *testing.T) {
+	got, _, err := ParseSessionsReport(" sid | ifname\n-----+-------\n")
+	if err != nil {
+		t.Fatalf("ParseSessionsReport: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("ParseSessionsReport = %d sessions, want 0", len(got))
+	}
+}
+
+// TestParseSessionsNotATable verifies output without the table header, such
+// as an empty reply or accel-ppp rejecting a column, is a parse error rather
+// than zero sessions.
+func TestParseSessionsNotATable(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"unknown column service-name\n",
+		" username | ip\n----------+---\n alice | 10.0.0.2\n",
+	} {
+		_, _, err := ParseSessionsReport(in)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("ParseSessionsReport(%q) = %v, want a ParseError", in, err)
+		}
+	}
+}
+
+// TestParseSessionsMalformedRow verifies a row with the wrong number of cells
+// (e.g. a "|" in a username) is skipped without losing its neighbours, and
+// that the problems are counted rather than logged row by row.
+func TestParseSessionsMalformedRow(t *testing.T) {
+	in := ` sid | ifname | username | rx-bytes-raw | uptime
+-----+--------+----------+--------------+---------
+ 1   | ppp0   | a|b      | 10           | 00:00:01
+ 2   | ppp1   | carol    | 20           | 00:00:02
+ 3   | ppp2   | dave     | lots         | soon
+ 4   | ppp3   | erin     |              |
+`
+	got, problems, err := ParseSessionsReport(in)
+	if err != nil {
+		t.Fatalf("ParseSessionsReport: %v", err)
+	}
+	if len(got) != 3 || got[0].Username != "carol" || got[0].RxBytes != 20 {
+		t.Errorf("sessions = %+v, want carol, dave and erin", got)
+	}
+	if want := (SessionProblems{SkippedRows: 1, MalformedValues: 2}); problems != want {
+		t.Errorf("problems = %+v, want %+v", problems, want)
+	}
+}
+
+func TestParseSessionUptime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"00:00:05", 5, true},
+		{"01:02:03", 3723, true},
+		{"3.04:05:06", 3*86400 + 4*3600 + 5*60 + 6, true},
+		{"", 0, true},
+		{"garbage", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := parseSessionUptime(tt.in); got != tt.want || ok != tt.ok {
+			t.Errorf("parseSessionUptime(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"123456", 123456, true},
+		{"0 B", 0, true},
+		{"512 B", 512, true},
+		{"1.5 KiB", 1536, true},
+		{"2.0 GiB", 2 << 30, true},
+		{"", 0, true},
+		{"bad MiB", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := parseBytes(tt.in); got != tt.want || ok != tt.ok {
+			t.Errorf("parseBytes(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
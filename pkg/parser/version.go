@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// VersionArgs are the accel-cmd arguments printing accel-ppp's version.
+var VersionArgs = []string{"show", "version"}
+
+// ParseVersion extracts the version from `show version` output, which is a
+// single line such as "1.12.0-168-ge7d7e2a" (git describe output for builds
+// from a checkout). A leading "accel-ppp" or "version" word, printed by some
+// packaged builds, is dropped. Anything that is not a version number, such
+// as an "invalid command" reply, is a ParseError rather than a version.
+func ParseVersion(output string) (string, error) {
+	for line := range strings.Lines(output) {
+		f := strings.Fields(line)
+		for len(f) > 0 && (strings.EqualFold(f[0], "accel-ppp") || strings.EqualFold(f[0], "version")) {
+			f = f[1:]
+		}
+		if len(f) > 0 {
+			if _, ok := parseVersionNumbers(f[0]); !ok {
+				return "", &ParseError{Err: fmt.Errorf("%q is not an accel-ppp version", strings.TrimSpace(line))}
+			}
+			return f[0], nil
+		}
+	}
+	return "", &ParseError{Err: errors.New("empty show version output")}
+}
@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"1.12.0-168-ge7d7e2a\r\n", "1.12.0-168-ge7d7e2a"},
+		{"1.13.0\n", "1.13.0"},
+		{"\naccel-ppp version 1.11.2\n", "1.11.2"},
+		{"accel-ppp 1.12.0", "1.12.0"},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseVersion(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+
+	var parseErr *ParseError
+	for _, in := range []string{" \n\n", "invalid command\n", "accel-ppp version unknown\n"} {
+		if v, err := ParseVersion(in); !errors.As(err, &parseErr) {
+			t.Errorf("ParseVersion(%q) = %q, %v, want *ParseError", in, v, err)
+		}
+	}
+}
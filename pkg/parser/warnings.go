@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Warning is a `show stat` line whose key the parser recognised but whose
+// value it could not parse, so the affected fields were recorded as zero. A
+// format change in accel-ppp typically shows up as warnings rather than
+// errors.
+type Warning struct {
+	Section string
+	Key     string
+	Value   string
+}
+
+func (w Warning) Error() string {
+	if w.Section == "" {
+		return fmt.Sprintf("malformed value %q for %s", w.Value, w.Key)
+	}
+	return fmt.Sprintf("malformed value %q for %s/%s", w.Value, w.Section, w.Key)
+}
+
+// parseNumber parses a numeric field. An empty value is 0 without error, as
+// accel-cmd legitimately omits fields.
+func parseNumber(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// warner collects Warnings while ParseStats walks the output. line sets the
+// line being parsed; however many of its values are malformed, each line
+// yields at most one Warning.
+type warner struct {
+	section, key, value string
+	warned              bool
+	warnings            []Warning
+}
+
+// line starts a new line.
+func (w *warner) line(section, key, value string) {
+	w.section, w.key, w.value, w.warned = section, key, value, false
+}
+
+// warn records a malformed value of the current line, logging the details
+// at debug level; callers report the Warning with their own context.
+func (w *warner) warn(format string, args ...any) {
+	slog.Debug("Malformed show stat value", "section", w.section, "key", w.key, "value", w.value, "err", fmt.Sprintf(format, args...))
+	if !w.warned {
+		w.warned = true
+		w.warnings = append(w.warnings, Warning{Section: w.section, Key: w.key, Value: w.value})
+	}
+}
+
+// atof parses a numeric field, yielding 0 and a warning when a non-empty
+// value fails to parse, so malformed output is visible instead of
+// masquerading as a real zero.
+func (w *warner) atof(value string) float64 {
+	f, err := parseNumber(value)
+	if err != nil {
+		w.warn("cannot parse %q as number: %v", value, err)
+	}
+	return f
+}
+
+// fields splits a "/"-delimited value (e.g. "10 / 1 / 0") into want floats.
+// It returns ok=false when the field count differs, so callers leave the
+// destination untouched rather than recording partial data. An empty value is
+// a silent miss (accel-cmd may omit a field); a non-empty value with the wrong
+// count is a warning.
+func (w *warner) fields(value string, want int) ([]float64, bool) {
+	if strings.TrimSpace(value) == "" {
+		return nil, false
+	}
+	parts := strings.Split(value, "/")
+	if len(parts) != want {
+		w.warn("expected %d fields in %q, got %d", want, value, len(parts))
+		return nil, false
+	}
+	out := make([]float64, want)
+	for i, p := range parts {
+		out[i] = w.atof(p)
+	}
+	return out, true
+}
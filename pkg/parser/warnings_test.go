@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseStatsWarnings(t *testing.T) {
+	st, err := ParseStats(sampleStat)
+	if err != nil {
+		t.Fatalf("ParseStats: %v", err)
+	}
+	if len(st.Warnings) != 0 {
+		t.Errorf("Warnings for sampleStat = %+v, want none", st.Warnings)
+	}
+
+	in := `uptime: forever
+cpu: 1.5%
+pppoe:
+  active: lots
+  state: not-a-counter
+core:
+  threads(count/active): 4 / 2 / 1
+`
+	if st, err = ParseStats(in); err != nil {
+		t.Fatalf("ParseStats: %v", err)
+	}
+	// Unknown keys are not the parser's to judge, so pppoe "state" is not a
+	// warning even though it is not numeric.
+	want := []Warning{
+		{Key: "uptime", Value: "forever"},
+		{Section: "pppoe", Key: "active", Value: "lots"},
+		{Section: "core", Key: "threads(count/active)", Value: "4 / 2 / 1"},
+	}
+	if !slices.Equal(st.Warnings, want) {
+		t.Errorf("Warnings = %+v, want %+v", st.Warnings, want)
+	}
+}
+
+func TestWarningError(t *testing.T) {
+	tests := []struct {
+		w    Warning
+		want string
+	}{
+		{Warning{Key: "uptime", Value: "forever"}, `malformed value "forever" for uptime`},
+		{Warning{Section: "pppoe", Key: "active", Value: "lots"}, `malformed value "lots" for pppoe/active`},
+	}
+	for _, tt := range tests {
+		if got := tt.w.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}